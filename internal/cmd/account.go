@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type stsGetCallerIdentityAPI interface {
+	GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// checkAWSAccount returns an error if the current credentials are for a
+// different account than aws.expected_account_id. With banner set, it also
+// logs the account and region, so that users of destructive commands can see
+// what they're about to change.
+func checkAWSAccount(ctx context.Context, stsClient stsGetCallerIdentityAPI, banner bool) error {
+	expected := rootConfig.AWS.ExpectedAccountID
+	if expected == "" && !banner {
+		return nil
+	}
+
+	identity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("getting AWS account: %w", err)
+	}
+	account := aws.ToString(identity.Account)
+	if banner {
+		log.Printf("Using AWS account %s in %s as %s", account, awsConfig.Region, aws.ToString(identity.Arn))
+	}
+	if expected != "" && account != expected {
+		return fmt.Errorf("current AWS account %s is not the expected account %s", account, expected)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// fakeSTS reports a fixed caller identity, and counts its calls.
+type fakeSTS struct {
+	account string
+	calls   int
+}
+
+func (f *fakeSTS) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	f.calls++
+	return &sts.GetCallerIdentityOutput{
+		Account: aws.String(f.account),
+		Arn:     aws.String("arn:aws:iam::" + f.account + ":user/hfc"),
+	}, nil
+}
+
+func TestCheckAWSAccount(t *testing.T) {
+	previous := rootConfig.AWS.ExpectedAccountID
+	t.Cleanup(func() { rootConfig.AWS.ExpectedAccountID = previous })
+
+	testCases := []struct {
+		description string
+		expected    string
+		banner      bool
+		wantCalls   int
+		wantErr     bool
+	}{
+		{"no expectation", "", false, 0, false},
+		{"banner only", "", true, 1, false},
+		{"matching", "123456789012", false, 1, false},
+		{"mismatched", "210987654321", false, 1, true},
+		{"mismatched with banner", "210987654321", true, 1, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			rootConfig.AWS.ExpectedAccountID = tc.expected
+			client := &fakeSTS{account: "123456789012"}
+			err := checkAWSAccount(context.Background(), client, tc.banner)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			if client.calls != tc.wantCalls {
+				t.Errorf("unexpected number of STS calls; got %d, want %d", client.calls, tc.wantCalls)
+			}
+		})
+	}
+}
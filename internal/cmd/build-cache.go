@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/featherbread/hfc/internal/ignore"
+	"github.com/featherbread/hfc/internal/shelley"
+)
+
+// goListPackageFilesTemplate is part of a go list template that prints the
+// paths of the files in a package that go build reads, one per line: the Go
+// and cgo sources, the other sources that cgo compiles, assembly, system
+// objects, embedded files, and the package's go.mod file.
+const goListPackageFilesTemplate = `{{$dir := .Dir}}` +
+	`{{range .GoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .CgoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .CFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .CXXFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .MFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .HFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .FFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .SFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .SwigFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .SwigCXXFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .SysoFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{range .EmbedFiles}}{{$dir}}/{{.}}{{"\n"}}{{end}}` +
+	`{{with .Module}}{{with .GoMod}}{{.}}{{"\n"}}{{end}}{{end}}`
+
+// goListInputsTemplate is a go list template that prints the paths of the files
+// that affect the build of each non-standard package, one per line.
+const goListInputsTemplate = `{{if not .Standard}}` + goListPackageFilesTemplate + `{{end}}`
+
+// buildHashPath returns the path to the file containing the hash of the inputs
+// to the latest successful build.
+func buildHashPath() string {
+	return rootState.Path("build-inputs-hash" + raceSuffix())
+}
+
+// isBuildCurrent returns true if the binary at outputPath exists and was built
+// from inputs with the provided hash.
+func isBuildCurrent(outputPath, inputsHash string) bool {
+	if _, err := os.Stat(outputPath); err != nil {
+		return false
+	}
+	previousHash, err := os.ReadFile(buildHashPath())
+	return err == nil && strings.TrimSpace(string(previousHash)) == inputsHash
+}
+
+// hashBuildInputs returns a hash of everything that affects the binary that
+// the build command would produce at outputPath: the Go version, build flags
+// and environment, and the contents of every source file in the non-standard
+// packages that the build depends on, except for files in the project that
+// .hfcignore excludes.
+func hashBuildInputs(outputPath string) (string, error) {
+	var stdout bytes.Buffer
+	goContext := &shelley.Context{
+		Stdout:      &stdout,
+		Stderr:      os.Stderr,
+		DebugLogger: shelley.DefaultContext.DebugLogger,
+	}
+
+	if err := withGoBuildEnv(goContext.Command(goBinary(), "env", "GOVERSION")).Run(); err != nil {
+		return "", err
+	}
+	goVersion := strings.TrimSpace(stdout.String())
+
+	stdout.Reset()
+	err := withGoBuildEnv(goContext.Command(
+		goBinary(), "list", "-deps",
+		"-tags", goBuildTags(),
+		"-f", goListInputsTemplate,
+		rootConfig.Build.Path,
+	)).Run()
+	if err != nil {
+		return "", err
+	}
+	files := slices.Compact(slices.Sorted(slices.Values(strings.Fields(stdout.String()))))
+	files, err = filterIgnoredFiles(files)
+	if err != nil {
+		return "", err
+	}
+
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "version %s\n", goVersion)
+	fmt.Fprintf(hash, "path %s\n", rootConfig.Build.Path)
+	fmt.Fprintf(hash, "output %s\n", absOutputPath)
+	fmt.Fprintf(hash, "flags %q\n", goBuildFlags())
+	for _, name := range slices.Sorted(maps.Keys(rootConfig.Build.Env)) {
+		fmt.Fprintf(hash, "env %s=%s\n", name, rootConfig.Build.Env[name])
+	}
+	for _, file := range files {
+		fileHash, err := hashFile(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "file %s %s\n", file, fileHash)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// filterIgnoredFiles removes the files that .hfcignore excludes from a list of
+// absolute paths. Only files within the project directory can be excluded.
+func filterIgnoredFiles(files []string) ([]string, error) {
+	projectDir := projectDir()
+	matcher, err := ignore.Load(filepath.Join(projectDir, ignore.Filename))
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, func(file string) bool {
+		rel, err := filepath.Rel(projectDir, file)
+		if err != nil || !filepath.IsLocal(rel) {
+			return false
+		}
+		return matcher.Match(filepath.ToSlash(rel), false)
+	}), nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
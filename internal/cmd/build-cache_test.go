@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/ignore"
+	"github.com/featherbread/hfc/internal/state"
+)
+
+func TestFilterIgnoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	previous := rootState
+	t.Cleanup(func() { rootState = previous })
+	rootState = state.Open(filepath.Join(dir, "hfc.toml"))
+
+	const patterns = "static/generated/\n*.txt\n!keep.txt\n"
+	if err := os.WriteFile(filepath.Join(dir, ignore.Filename), []byte(patterns), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(filepath.Dir(dir), "module", "notes.txt")
+	files := []string{
+		filepath.Join(dir, "main.go"),
+		filepath.Join(dir, "static", "generated", "app.js"),
+		filepath.Join(dir, "static", "notes.txt"),
+		filepath.Join(dir, "static", "keep.txt"),
+		outside,
+	}
+	got, err := filterIgnoredFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "main.go"),
+		filepath.Join(dir, "static", "keep.txt"),
+		outside,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected files (-want +got):\n%s", diff)
+	}
+}
+
+func TestRaceBuildPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	previousState, previousConfig := rootState, rootConfig
+	t.Cleanup(func() { rootState, rootConfig, buildRace = previousState, previousConfig, false })
+	rootState = state.Open(filepath.Join(dir, "hfc.toml"))
+	rootConfig.Project.Name = "app"
+
+	normalBinary, err := binaryPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalHash := buildHashPath()
+
+	buildRace = true
+	raceBinary, err := binaryPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := normalBinary + "-race"; raceBinary != want {
+		t.Errorf("unexpected race binary path; got %q, want %q", raceBinary, want)
+	}
+	if raceHash := buildHashPath(); raceHash == normalHash {
+		t.Errorf("race build shares build hash path %q with normal builds", raceHash)
+	}
+}
+
+func TestHashBuildInputs(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+
+	files := map[string]string{
+		"go.mod":       "module example.com/app\n\ngo 1.22\n",
+		"main.go":      "package main\n\nimport _ \"embed\"\n\n//go:embed data.txt\nvar data string\n\nfunc main() {}\n",
+		"main_test.go": "package main\n",
+		"asm_arm64.s":  "// assembly\n",
+		"res.syso":     "object",
+		"data.txt":     "data",
+		"README.md":    "# app\n",
+	}
+	testCases := []struct {
+		description string
+		file        string
+		wantMiss    bool
+	}{
+		{"Go source", "main.go", true},
+		{"assembly", "asm_arm64.s", true},
+		{"system object", "res.syso", true},
+		{"embedded file", "data.txt", true},
+		{"module", "go.mod", true},
+		{"test file", "main_test.go", false},
+		{"unrelated file", "README.md", false},
+	}
+
+	previousState, previousConfig := rootState, rootConfig
+	t.Cleanup(func() { rootState, rootConfig = previousState, previousConfig })
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rootState = state.Open(filepath.Join(dir, "hfc.toml"))
+			rootConfig = config.Config{Build: config.BuildConfig{Path: "."}}
+			outputPath := filepath.Join(dir, ".hfc", "output", "app")
+
+			before, err := hashBuildInputs(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, tc.file)
+			if err := os.WriteFile(path, []byte(files[tc.file]+"\n// changed\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			after, err := hashBuildInputs(outputPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if gotMiss := before != after; gotMiss != tc.wantMiss {
+				t.Errorf("unexpected cache miss after changing %s; got %v, want %v", tc.file, gotMiss, tc.wantMiss)
+			}
+		})
+	}
+}
@@ -1,22 +1,105 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/kballard/go-shellquote"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
 
 var buildDeployCmd = &cobra.Command{
-	Use:               "build-deploy [flags] stack [parameters]",
-	Short:             "Build, upload, and deploy all at once",
+	Use:   "build-deploy [flags] stack [parameters]",
+	Short: "Build, upload, and deploy all at once",
+	Long: `Build, upload, and deploy all at once
+
+The build-deploy command runs build, upload, and deploy in order, and stops at
+the first phase that fails. It checks the stack name and parameters before
+building, so that a mistake in the command line doesn't leave behind an upload
+that was never deployed.
+`,
 	Args:              cobra.MinimumNArgs(1),
-	ValidArgsFunction: completeStackNames,
-	PreRun:            initializePreRun,
-	Run:               runBuildDeploy,
+	ValidArgsFunction: completeParameterKeys,
+	PreRunE:           initializePreRun,
+	RunE:              runBuildDeploy,
 }
 
 func init() {
+	buildDeployCmd.Flags().BoolVarP(&confirmYes, "yes", "y", false, "Do not ask for confirmation before deploying protected stacks")
+	buildDeployCmd.Flags().BoolVar(&buildForce, "force", false, "Build even if the build inputs are unchanged since the last build")
+	buildDeployCmd.Flags().StringVar(&buildOutputDir, "output-dir", "", "Write the binary to this directory instead of the state directory")
+	addParameterFlag(buildDeployCmd)
+	addCapabilityFlag(buildDeployCmd)
+	addTimingsFlag(buildDeployCmd)
 	rootCmd.AddCommand(buildDeployCmd)
 }
 
-func runBuildDeploy(cmd *cobra.Command, args []string) {
-	runBuild(cmd, args)
-	runUpload(cmd, args)
-	runDeploy(cmd, args)
+func runBuildDeploy(cmd *cobra.Command, args []string) error {
+	if err := rootConfig.CheckRequired("project.name", "build.path", "upload.bucket", "template.path"); err != nil {
+		return err
+	}
+	if _, err := findStacks(args[0]); err != nil {
+		return err
+	}
+	if _, err := cliDeployParameters(args[1:]); err != nil {
+		return err
+	}
+
+	defer writePhaseTimings()
+	defer logPhaseTimings("build-deploy")
+
+	if err := timePhase("build", func() error { return runBuild(cmd, args) }); err != nil {
+		return buildDeployPhaseError("build", err)
+	}
+	if err := timePhase("upload", func() error { return runUpload(cmd, args) }); err != nil {
+		return buildDeployPhaseError("upload", err)
+	}
+	if err := timePhase("deploy", func() error { return runDeploy(cmd, args) }); err != nil {
+		// Once a change set is applied, the stack uses the upload, and the
+		// failure came later, like from a post-deploy hook.
+		if key, keyErr := rootState.ReadLatestPackage(); keyErr == nil && !deployAppliedChangeSet {
+			log.Printf("Uploaded %s, but did not deploy it; retry with: %s", key, deployRetryCommand(cmd, args))
+		}
+		return buildDeployPhaseError("deploy", err)
+	}
+	return nil
+}
+
+// deployRetryCommand returns a deploy command line that repeats the deploy
+// phase of build-deploy, with the same arguments and the flags that deploy
+// shares with build-deploy.
+func deployRetryCommand(cmd *cobra.Command, args []string) string {
+	words := []string{"hfc", "deploy"}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if deployCmd.Flags().Lookup(flag.Name) == nil && rootCmd.PersistentFlags().Lookup(flag.Name) == nil {
+			return
+		}
+		if flag.Value.Type() == "bool" {
+			words = append(words, "--"+flag.Name+lo.Ternary(flag.Value.String() == "true", "", "=false"))
+			return
+		}
+		values := []string{flag.Value.String()}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			values = slice.GetSlice()
+		}
+		for _, value := range values {
+			words = append(words, "--"+flag.Name+"="+value)
+		}
+	})
+	return shellquote.Join(append(words, args...)...)
+}
+
+// buildDeployPhaseError reports that a phase of build-deploy failed. Phases
+// that exit with an exitCode have already logged the reason, so only the
+// phase is logged for them.
+func buildDeployPhaseError(phase string, err error) error {
+	var code exitCode
+	if errors.As(err, &code) {
+		log.Printf("Stopped build-deploy because %s failed", phase)
+		return err
+	}
+	return fmt.Errorf("%s failed: %w", phase, err)
 }
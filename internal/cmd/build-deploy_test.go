@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestDeployRetryCommand(t *testing.T) {
+	cmd := &cobra.Command{Use: "build-deploy"}
+	cmd.Flags().Bool("yes", false, "")
+	cmd.Flags().Bool("force", false, "")
+	cmd.Flags().StringArray("parameter", nil, "")
+	cmd.Flags().StringArray("capability", nil, "")
+	cmd.Flags().String("env", "", "")
+	err := cmd.ParseFlags([]string{
+		"--yes", "--force", "--env=staging",
+		"--parameter", "Domain=example.com", "--parameter", "Greeting=hello world",
+		"--capability", "CAPABILITY_IAM",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := deployRetryCommand(cmd, []string{"HFCStaging", "LogLevel=debug"})
+	want := "hfc deploy --capability=CAPABILITY_IAM --env=staging " +
+		"--parameter=Domain=example.com '--parameter=Greeting=hello world' --yes " +
+		"HFCStaging LogLevel=debug"
+	if got != want {
+		t.Errorf("unexpected retry command\ngot:  %s\nwant: %s", got, want)
+	}
+}
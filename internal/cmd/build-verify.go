@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+
+	"github.com/samber/lo"
+)
+
+// goBuildTarget returns the operating system and architecture that builds
+// target, which are linux and arm64 unless build.env overrides them.
+func goBuildTarget() (goos, goarch string) {
+	return lo.CoalesceOrEmpty(rootConfig.Build.Env["GOOS"], "linux"),
+		lo.CoalesceOrEmpty(rootConfig.Build.Env["GOARCH"], "arm64")
+}
+
+// elfMachines maps Go architectures to the machine types of their ELF
+// binaries.
+var elfMachines = map[string]elf.Machine{
+	"386":      elf.EM_386,
+	"amd64":    elf.EM_X86_64,
+	"arm":      elf.EM_ARM,
+	"arm64":    elf.EM_AARCH64,
+	"loong64":  elf.EM_LOONGARCH,
+	"mips":     elf.EM_MIPS,
+	"mipsle":   elf.EM_MIPS,
+	"mips64":   elf.EM_MIPS,
+	"mips64le": elf.EM_MIPS,
+	"ppc64":    elf.EM_PPC64,
+	"ppc64le":  elf.EM_PPC64,
+	"riscv64":  elf.EM_RISCV,
+	"s390x":    elf.EM_S390,
+}
+
+// verifyBinaryTarget checks that the binary at path is a Linux ELF executable
+// for goarch, to catch a build environment that accidentally produced a binary
+// for the host instead of for Lambda. Only Linux targets can be verified, so
+// other values of goos, or architectures that hfc doesn't know, pass without a
+// check.
+func verifyBinaryTarget(path, goos, goarch string) error {
+	wantMachine, ok := elfMachines[goarch]
+	if goos != "linux" || !ok {
+		return nil
+	}
+
+	file, err := elf.Open(path)
+	var formatErr *elf.FormatError
+	if errors.As(err, &formatErr) {
+		return fmt.Errorf("%s is not a linux/%s binary: not an ELF file", path, goarch)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if file.Machine != wantMachine {
+		return fmt.Errorf("%s is not a linux/%s binary: built for machine type %v", path, goarch, file.Machine)
+	}
+	// Go leaves the OS ABI unset on Linux, but sets it for other systems that
+	// use ELF, like FreeBSD.
+	if file.OSABI != elf.ELFOSABI_NONE && file.OSABI != elf.ELFOSABI_LINUX {
+		return fmt.Errorf("%s is not a linux/%s binary: built for OS ABI %v", path, goarch, file.OSABI)
+	}
+	if file.Type != elf.ET_EXEC && file.Type != elf.ET_DYN {
+		return fmt.Errorf("%s is not a linux/%s binary: ELF file type is %v", path, goarch, file.Type)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBinaryTarget(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test uses its own binary as a Linux executable")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherArch := "arm64"
+	if runtime.GOARCH == "arm64" {
+		otherArch = "amd64"
+	}
+	notELF := filepath.Join(t.TempDir(), "handler")
+	if err := os.WriteFile(notELF, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		description string
+		path        string
+		goos        string
+		goarch      string
+		wantErr     string
+	}{
+		{"matching", self, "linux", runtime.GOARCH, ""},
+		{"wrong architecture", self, "linux", otherArch, "machine type"},
+		{"not ELF", notELF, "linux", runtime.GOARCH, "not an ELF file"},
+		{"unverifiable OS", notELF, "darwin", "arm64", ""},
+		{"unknown architecture", notELF, "linux", "wasm", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := verifyBinaryTarget(tc.path, tc.goos, tc.goarch)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("unexpected error; got %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -1,57 +1,234 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/featherbread/hfc/internal/shelley"
 )
 
 var buildCmd = &cobra.Command{
-	Use:    "build",
-	Short:  "Build the Go binary for Lambda",
-	PreRun: initializePreRun,
-	Run:    runBuild,
+	Use:   "build",
+	Short: "Build the Go binary for Lambda",
+	Long: `Build the Go binary for Lambda
+
+The build command cross-compiles the configured package for Lambda's arm64
+Linux environment, with cgo disabled.
+
+The --race flag builds with the race detector for running the binary in a local
+test harness. The race detector requires cgo, so --race enables it, and the
+binary is not suitable for deployment to Lambda. It's written with a "-race"
+suffix on its name, where upload never finds it. Cross-compiling with cgo needs
+a C toolchain for the target, which build.env can select with CC, or the build
+can target the local machine by overriding GOARCH.
+
+The build is skipped when its inputs are unchanged since the last build, unless
+--force is given. The inputs are the Go version, the build settings, and the
+files of every package in the build that go build reads, as reported by go list:
+Go, cgo, and assembly sources, system objects, and embedded files. Go alone
+decides which files are compiled: files that Go ignores, like tests and
+documentation, never affect the inputs.
+
+A .hfcignore file next to the configuration can exclude more files from the
+inputs, using the syntax of .gitignore with paths relative to its directory.
+This is for embedded files that don't matter to the build, like generated
+assets that change on every run. A change to an excluded file won't trigger a
+new build, but is still included in the binary whenever one is built, so only
+exclude files that are safe to deploy stale.
+`,
+	PreRunE: initializePreRun,
+	RunE:    withPhaseTiming("build", runBuild),
 }
 
+var (
+	buildClean     bool
+	buildForce     bool
+	buildOutputDir string
+	buildRace      bool
+	buildGCFlags   string
+)
+
 func init() {
+	buildCmd.Flags().BoolVar(&buildClean, "clean", false, "Remove the entire output directory before building")
+	buildCmd.Flags().BoolVar(&buildForce, "force", false, "Build even if the build inputs are unchanged since the last build")
+	buildCmd.Flags().StringVar(&buildOutputDir, "output-dir", "", "Write the binary to this directory instead of the state directory")
+	buildCmd.MarkFlagsMutuallyExclusive("clean", "output-dir")
+	buildCmd.Flags().BoolVar(&buildRace, "race", false, "Enable the race detector, for local testing only (requires cgo)")
+	buildCmd.Flags().StringVar(&buildGCFlags, "gcflags", "", "Pass these flags to the Go compiler, as with go build -gcflags")
+	addTimingsFlag(buildCmd)
 	rootCmd.AddCommand(buildCmd)
 }
 
-func runBuild(cmd *cobra.Command, args []string) {
-	outputPath, err := rootState.BinaryPath(rootConfig.Project.Name)
+// binaryPath returns the path to the project's binary, which is in the state
+// directory unless --output-dir overrides it.
+func binaryPath() (string, error) {
+	return rootState.BinaryPathIn(buildOutputDir, rootConfig.Project.Name+raceSuffix())
+}
+
+// raceSuffix returns the suffix for the names of files produced by builds with
+// the race detector, which must never be mistaken for deployable builds.
+func raceSuffix() string {
+	return lo.Ternary(buildRace, "-race", "")
+}
+
+func runBuild(cmd *cobra.Command, args []string) error {
+	if err := rootConfig.CheckRequired("project.name", "build.path"); err != nil {
+		return err
+	}
+	outputPath, err := binaryPath()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
+	if _, err := exec.LookPath(goBinary()); err != nil {
+		return fmt.Errorf("go command not found: %w", err)
+	}
+	if buildRace {
+		slog.Warn("building with the race detector and cgo enabled; the binary is for local testing, not for deployment to Lambda")
+	}
+
+	if err := runHooks(rootConfig.Hooks.PreBuild, ""); err != nil {
+		return err
+	}
+
+	// Pre-build hooks may generate source files, so the inputs must be hashed
+	// after they run.
+	inputsHash, err := hashBuildInputs(outputPath)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("unable to hash build inputs, build cache is disabled: %v", err))
+	}
+	if inputsHash != "" && !buildForce && !buildClean && isBuildCurrent(outputPath, inputsHash) {
+		// Post-build hooks may copy or sign the binary, so they run after a
+		// cache hit just as after a build.
+		log.Printf("Build inputs are unchanged, reusing %s", outputPath)
+		return runHooks(rootConfig.Hooks.PostBuild, "")
+	}
+
+	// Remove any previous binary, so that a failed build can't leave a stale one
+	// behind for upload to pick up.
 	outputDir := filepath.Dir(outputPath)
-	if err := os.RemoveAll(outputDir); err != nil {
-		log.Fatal("cleaning output directory: ", err)
+	if buildClean {
+		if err := os.RemoveAll(outputDir); err != nil {
+			return fmt.Errorf("cleaning output directory: %w", err)
+		}
+	} else if err := os.Remove(outputPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing previous binary: %w", err)
+	}
+	if err := os.Remove(buildHashPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing previous build hash: %w", err)
 	}
 	if err := os.MkdirAll(outputDir, fs.ModeDir|0755); err != nil {
-		log.Fatal("creating output directory: ", err)
+		return fmt.Errorf("creating output directory: %w", err)
 	}
 
+	// The go command runs in the project directory, so it needs an absolute
+	// output path.
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return err
+	}
+	// Build output streams to stderr as usual, and is also captured so that a
+	// failure can report it along with the error, where it's easy to find in CI
+	// logs.
+	var output bytes.Buffer
+	tee := io.MultiWriter(shelley.DefaultContext.Stderr, &output)
+	goContext := &shelley.Context{
+		Stdout:      tee,
+		Stderr:      tee,
+		DebugLogger: shelley.DefaultContext.DebugLogger,
+	}
+	goBuild := withGoBuildEnv(goContext.Command(slices.Concat(
+		[]string{goBinary(), "build", "-v"},
+		goBuildFlags(),
+		[]string{"-o", absOutputPath, rootConfig.Build.Path},
+	)...))
+	if err := goBuild.Run(); err != nil {
+		// An interrupted build may leave a partial binary behind.
+		os.Remove(outputPath)
+		return fmt.Errorf("go build failed: %w\n%s", err, bytes.TrimSpace(output.Bytes()))
+	}
+
+	goos, goarch := goBuildTarget()
+	if err := verifyBinaryTarget(outputPath, goos, goarch); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("%w (check GOOS and GOARCH in build.env)", err)
+	}
+
+	stat, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("Built %s (%s)", outputPath, formatBytes(stat.Size()))
+	if stat.Size() > lambdaUnzippedSizeLimit {
+		slog.Warn(fmt.Sprintf("binary exceeds the %s limit for unzipped Lambda packages", formatBytes(lambdaUnzippedSizeLimit)))
+	}
+
+	if inputsHash != "" {
+		if err := os.WriteFile(buildHashPath(), []byte(inputsHash+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	return runHooks(rootConfig.Hooks.PostBuild, "")
+}
+
+// goBuildLDFlags are the linker flags for every build, which strip debug info
+// to keep binaries small.
+const goBuildLDFlags = "-s -w"
+
+// goBinary returns the name or path of the go command for builds.
+func goBinary() string {
+	return lo.CoalesceOrEmpty(rootConfig.Build.GoBinary, "go")
+}
+
+// goBuildFlags returns the flags for go build that affect the binary, other
+// than the output path.
+func goBuildFlags() []string {
+	flags := []string{"-ldflags", goBuildLDFlags, "-tags", goBuildTags()}
+	if buildRace {
+		flags = append(flags, "-race")
+	}
+	if buildGCFlags != "" {
+		flags = append(flags, "-gcflags", buildGCFlags)
+	}
+	return flags
+}
+
+// goBuildTags returns the value of the -tags flag for go commands, including
+// the configured build tags.
+func goBuildTags() string {
 	var tags strings.Builder
 	tags.WriteString("lambda.norpc")
 	for _, tag := range rootConfig.Build.Tags {
 		tags.WriteRune(',')
 		tags.WriteString(tag)
 	}
+	return tags.String()
+}
 
-	shelley.ExitIfError(shelley.
-		Command(
-			"go", "build", "-v",
-			"-ldflags", "-s -w",
-			"-tags", tags.String(),
-			"-o", outputPath,
-			rootConfig.Build.Path,
-		).
-		Env("CGO_ENABLED", "0").Env("GOOS", "linux").Env("GOARCH", "arm64").
-		Run())
+// withGoBuildEnv sets the environment for cross-compiling to Lambda on a go
+// command, followed by the configured build environment. Cgo is disabled
+// unless the race detector, which requires it, is enabled. The command runs in
+// the project directory, so that build.path is relative to the configuration.
+func withGoBuildEnv(cmd *shelley.Cmd) *shelley.Cmd {
+	cmd.Dir(projectDir())
+	cmd.Env("CGO_ENABLED", lo.Ternary(buildRace, "1", "0")).Env("GOOS", "linux").Env("GOARCH", "arm64")
+	for _, name := range slices.Sorted(maps.Keys(rootConfig.Build.Env)) {
+		cmd.Env(name, rootConfig.Build.Env[name])
+	}
+	return cmd
 }
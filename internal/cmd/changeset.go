@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/samber/lo"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+// createChangeSet creates a change set that deploys the template to the stack
+// with the provided "Key=Value" parameters, waits for CloudFormation to finish
+// computing it, and returns its full description along with whether it creates
+// or updates the stack.
+//
+// A change set that failed because the stack is already up to date is returned
+// without error, and reports no changes.
+func createChangeSet(ctx context.Context, cfnClient *cloudformation.Client, stack config.StackConfig, template cfnTemplate, parameters []string) (*cloudformation.DescribeChangeSetOutput, types.ChangeSetType, error) {
+	// Stacks that don't exist yet, or that only exist to hold an unexecuted
+	// change set, can only be deployed with a CREATE change set.
+	changeSetType := types.ChangeSetTypeUpdate
+	current, err := describeStack(ctx, cfnClient, stack.Name)
+	switch {
+	case errors.As(err, new(stackNotFoundError)):
+		changeSetType = types.ChangeSetTypeCreate
+	case err != nil:
+		return nil, "", err
+	case current.StackStatus == types.StackStatusReviewInProgress:
+		changeSetType = types.ChangeSetTypeCreate
+	}
+
+	changeSetParameters := changeSetParameters(parameters)
+	if changeSetType == types.ChangeSetTypeUpdate {
+		// Like the AWS CLI's deploy command, keep the previous values of any
+		// template parameters that weren't provided.
+		summary, err := cfnClient.GetTemplateSummary(ctx, &cloudformation.GetTemplateSummaryInput{
+			TemplateBody: template.Body,
+			TemplateURL:  template.URL,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, declaration := range summary.Parameters {
+			key := aws.ToString(declaration.ParameterKey)
+			provided := slices.ContainsFunc(changeSetParameters, func(p types.Parameter) bool { return aws.ToString(p.ParameterKey) == key })
+			_, previous := getStackParameter(current, key)
+			if !provided && previous {
+				changeSetParameters = append(changeSetParameters, types.Parameter{
+					ParameterKey:     aws.String(key),
+					UsePreviousValue: aws.Bool(true),
+				})
+			}
+		}
+	}
+
+	created, err := cfnClient.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		StackName:     aws.String(stack.Name),
+		ChangeSetName: aws.String("hfc-" + strconv.FormatInt(time.Now().Unix(), 10)),
+		ChangeSetType: changeSetType,
+		TemplateBody:  template.Body,
+		TemplateURL:   template.URL,
+		Parameters:    changeSetParameters,
+		Capabilities: lo.Map(deployCapabilities(), func(c string, _ int) types.Capability {
+			return types.Capability(c)
+		}),
+		RoleARN:          lo.EmptyableToPtr(lo.CoalesceOrEmpty(stack.RoleARN, rootConfig.Template.RoleARN)),
+		NotificationARNs: rootConfig.Template.NotificationARNs,
+	})
+	if err != nil {
+		return nil, "", withCapabilitiesHint(err)
+	}
+
+	var changeSet *cloudformation.DescribeChangeSetOutput
+	err = poll(ctx, defaultPollOptions, func() (bool, error) {
+		changeSet, err = cfnClient.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{
+			ChangeSetName: created.Id,
+		})
+		if err != nil {
+			return false, err
+		}
+		switch changeSet.Status {
+		case types.ChangeSetStatusCreatePending, types.ChangeSetStatusCreateInProgress:
+			return false, nil
+		case types.ChangeSetStatusFailed:
+			if !changeSetHasNoChanges(changeSet) {
+				return false, withCapabilitiesHint(fmt.Errorf("change set failed: %s", aws.ToString(changeSet.StatusReason)))
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		if changeSetType == types.ChangeSetTypeCreate {
+			placeholder := &cloudformation.DescribeChangeSetOutput{ChangeSetId: created.Id, StackId: created.StackId}
+			err = errors.Join(err, deleteChangeSet(ctx, cfnClient, placeholder, changeSetType))
+		}
+		return nil, "", err
+	}
+
+	for nextToken := changeSet.NextToken; nextToken != nil; {
+		page, err := cfnClient.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{
+			ChangeSetName: created.Id,
+			NextToken:     nextToken,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		changeSet.Changes = append(changeSet.Changes, page.Changes...)
+		nextToken = page.NextToken
+	}
+	changeSet.NextToken = nil
+	return changeSet, changeSetType, nil
+}
+
+// deployCapabilities returns the capabilities to acknowledge for a deployment,
+// from the configuration and the --capability flag.
+func deployCapabilities() []string {
+	return lo.Uniq(slices.Concat(rootConfig.Template.Capabilities, deployCapabilityFlags))
+}
+
+// requiredCapabilitiesPattern matches the capabilities that CloudFormation
+// reports as missing when a template needs more than a deployment
+// acknowledged, like "Requires capabilities : [CAPABILITY_IAM]".
+var requiredCapabilitiesPattern = regexp.MustCompile(`(?i)requires capabilities\s*:\s*\[([A-Z_,\s]*)\]`)
+
+// withCapabilitiesHint adds the way to acknowledge the missing capabilities to
+// an error for a template that requires them, since CloudFormation's message
+// alone doesn't say how to do that with hfc. Other errors are returned
+// unchanged.
+func withCapabilitiesHint(err error) error {
+	match := requiredCapabilitiesPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	capabilities := lo.Compact(lo.Map(strings.Split(match[1], ","), func(c string, _ int) string {
+		return strings.TrimSpace(c)
+	}))
+	if len(capabilities) == 0 {
+		return err
+	}
+	return fmt.Errorf(
+		"%w; add %s to template.capabilities in the configuration, or acknowledge them for one deployment with --capability",
+		err, `"`+strings.Join(capabilities, `", "`)+`"`)
+}
+
+// changeSetHasNoChanges returns true if the change set failed only because the
+// stack is already up to date with its template and parameters.
+func changeSetHasNoChanges(changeSet *cloudformation.DescribeChangeSetOutput) bool {
+	reason := aws.ToString(changeSet.StatusReason)
+	return changeSet.Status == types.ChangeSetStatusFailed &&
+		(strings.Contains(reason, "didn't contain changes") || strings.Contains(reason, "No updates are to be performed"))
+}
+
+// changeSetParameters converts "Key=Value" parameter strings into CloudFormation
+// parameters. When a key appears more than once, the last value wins.
+func changeSetParameters(parameters []string) []types.Parameter {
+	var result []types.Parameter
+	indexes := make(map[string]int)
+	for _, p := range parameters {
+		key, value, _ := strings.Cut(p, "=")
+		parameter := types.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(value)}
+		if i, ok := indexes[key]; ok {
+			result[i] = parameter
+			continue
+		}
+		indexes[key] = len(result)
+		result = append(result, parameter)
+	}
+	return result
+}
+
+// changeSetRecord is the JSON representation of a change set saved with
+// deploy --changeset-output.
+type changeSetRecord struct {
+	StackName  string
+	HasChanges bool
+	ChangeSet  *cloudformation.DescribeChangeSetOutput
+}
+
+// executeChangeSet executes a change set returned by createChangeSet, and waits
+// up to maxWait for the stack to finish creating or updating.
+func executeChangeSet(ctx context.Context, cfnClient *cloudformation.Client, changeSet *cloudformation.DescribeChangeSetOutput, changeSetType types.ChangeSetType, maxWait time.Duration) error {
+	_, err := cfnClient.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{
+		ChangeSetName: changeSet.ChangeSetId,
+	})
+	if err != nil {
+		return err
+	}
+
+	stackName := aws.ToString(changeSet.StackName)
+	input := &cloudformation.DescribeStacksInput{StackName: changeSet.StackId}
+	if changeSetType == types.ChangeSetTypeCreate {
+		log.Printf("Waiting for %s to be created", stackName)
+		err = cloudformation.NewStackCreateCompleteWaiter(cfnClient).Wait(ctx, input, maxWait,
+			func(o *cloudformation.StackCreateCompleteWaiterOptions) {
+				o.MinDelay, o.MaxDelay = defaultPollOptions.MinInterval, defaultPollOptions.MaxInterval
+				o.Retryable = logStackWaiterStatus(stackName, o.Retryable)
+			})
+	} else {
+		log.Printf("Waiting for %s to be updated", stackName)
+		err = cloudformation.NewStackUpdateCompleteWaiter(cfnClient).Wait(ctx, input, maxWait,
+			func(o *cloudformation.StackUpdateCompleteWaiterOptions) {
+				o.MinDelay, o.MaxDelay = defaultPollOptions.MinInterval, defaultPollOptions.MaxInterval
+				o.Retryable = logStackWaiterStatus(stackName, o.Retryable)
+			})
+	}
+	if err != nil {
+		// The waiter's own errors don't say why the deployment failed, but the
+		// stack's status reason usually does.
+		if stack, describeErr := describeStack(ctx, cfnClient, aws.ToString(changeSet.StackId)); describeErr == nil {
+			return fmt.Errorf("stack %s is %s: %s (%w)", stackName, stack.StackStatus, aws.ToString(stack.StackStatusReason), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// loadStackPolicy returns the body of the stack policy for the stack, which
+// may be configured as inline JSON or as the path to a JSON file, or an empty
+// string if the stack has no policy.
+func loadStackPolicy(stack config.StackConfig) (string, error) {
+	policy := lo.CoalesceOrEmpty(stack.StackPolicy, rootConfig.Template.StackPolicy)
+	if policy == "" {
+		return "", nil
+	}
+
+	body := policy
+	if !config.IsInlinePolicy(policy) {
+		data, err := os.ReadFile(policy)
+		if err != nil {
+			return "", fmt.Errorf("reading stack policy: %w", err)
+		}
+		body = string(data)
+	}
+	if !json.Valid([]byte(body)) {
+		return "", fmt.Errorf("stack policy for %s is not valid JSON", stack.Name)
+	}
+	return body, nil
+}
+
+// setStackPolicy applies the stack policy to the named stack.
+func setStackPolicy(ctx context.Context, cfnClient *cloudformation.Client, stackName, policy string) error {
+	_, err := cfnClient.SetStackPolicy(ctx, &cloudformation.SetStackPolicyInput{
+		StackName:       aws.String(stackName),
+		StackPolicyBody: aws.String(policy),
+	})
+	if err != nil {
+		return fmt.Errorf("setting stack policy: %w", err)
+	}
+	log.Printf("Applied stack policy to %s", stackName)
+	return nil
+}
+
+// cfnDeleteChangeSetAPI is the subset of the CloudFormation API used to
+// delete change sets that will not be executed.
+type cfnDeleteChangeSetAPI interface {
+	DeleteChangeSet(context.Context, *cloudformation.DeleteChangeSetInput, ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error)
+	DeleteStack(context.Context, *cloudformation.DeleteStackInput, ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+}
+
+// deleteChangeSet deletes a change set that will not be executed. Creating a
+// change set of type CREATE also creates an empty stack in the
+// REVIEW_IN_PROGRESS state, which would block the stack name, so that stack is
+// deleted instead, along with the change set.
+func deleteChangeSet(ctx context.Context, cfnClient cfnDeleteChangeSetAPI, changeSet *cloudformation.DescribeChangeSetOutput, changeSetType types.ChangeSetType) error {
+	if changeSetType == types.ChangeSetTypeCreate {
+		_, err := cfnClient.DeleteStack(ctx, &cloudformation.DeleteStackInput{
+			StackName: changeSet.StackId,
+		})
+		return err
+	}
+	_, err := cfnClient.DeleteChangeSet(ctx, &cloudformation.DeleteChangeSetInput{
+		ChangeSetName: changeSet.ChangeSetId,
+	})
+	return err
+}
+
+// writeChangeSetRecord writes the full description of a change set to path as
+// JSON, including whether the change set has any changes.
+func writeChangeSetRecord(changeSet *cloudformation.DescribeChangeSetOutput, path string) error {
+	record := changeSetRecord{
+		StackName:  aws.ToString(changeSet.StackName),
+		HasChanges: len(changeSet.Changes) > 0,
+		ChangeSet:  changeSet,
+	}
+	body, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(body, '\n'), 0644)
+}
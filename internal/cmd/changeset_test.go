@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestChangeSetParameters(t *testing.T) {
+	parameter := func(key, value string) types.Parameter {
+		return types.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(value)}
+	}
+
+	got := changeSetParameters([]string{
+		"CodeS3Bucket=hfc",
+		"Environment=staging",
+		"Query=a=b",
+		"Empty=",
+		"Environment=production",
+	})
+	want := []types.Parameter{
+		parameter("CodeS3Bucket", "hfc"),
+		parameter("Environment", "production"),
+		parameter("Query", "a=b"),
+		parameter("Empty", ""),
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(types.Parameter{})); diff != "" {
+		t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadStackPolicy(t *testing.T) {
+	const filePolicy = `{"Statement": [{"Effect": "Allow", "Action": "Update:*", "Principal": "*", "Resource": "*"}]}`
+	const inlinePolicy = `{"Statement": [{"Effect": "Deny", "Action": "Update:Replace", "Principal": "*", "Resource": "*"}]}`
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyPath, []byte(filePolicy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := rootConfig.Template.StackPolicy
+	t.Cleanup(func() { rootConfig.Template.StackPolicy = previous })
+	rootConfig.Template.StackPolicy = policyPath
+
+	testCases := []struct {
+		description string
+		stack       config.StackConfig
+		want        string
+		wantErr     bool
+	}{
+		{"template file", config.StackConfig{Name: "HFCStaging"}, filePolicy, false},
+		{"stack inline", config.StackConfig{Name: "HFCProduction", StackPolicy: "  " + inlinePolicy}, "  " + inlinePolicy, false},
+		{"invalid inline", config.StackConfig{Name: "HFCProduction", StackPolicy: "{"}, "", true},
+		{"missing file", config.StackConfig{Name: "HFCProduction", StackPolicy: "missing.json"}, "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := loadStackPolicy(tc.stack)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected policy; got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithCapabilitiesHint(t *testing.T) {
+	testCases := []struct {
+		description string
+		err         error
+		want        string
+	}{{
+		description: "one capability",
+		err:         errors.New("InsufficientCapabilitiesException: Requires capabilities : [CAPABILITY_IAM]"),
+		want:        `"CAPABILITY_IAM"`,
+	}, {
+		description: "several capabilities",
+		err:         errors.New("change set failed: Requires capabilities : [CAPABILITY_NAMED_IAM, CAPABILITY_AUTO_EXPAND]"),
+		want:        `"CAPABILITY_NAMED_IAM", "CAPABILITY_AUTO_EXPAND"`,
+	}, {
+		description: "unrelated",
+		err:         errors.New("change set failed: Template format error"),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got := withCapabilitiesHint(tc.err)
+			if !errors.Is(got, tc.err) {
+				t.Errorf("hint does not wrap the original error: %v", got)
+			}
+			if tc.want == "" {
+				if got != tc.err {
+					t.Errorf("unexpected hint for unrelated error: %v", got)
+				}
+				return
+			}
+			if !strings.Contains(got.Error(), "add "+tc.want+" to template.capabilities") {
+				t.Errorf("unexpected hint; got %q, want capabilities %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeployCapabilities(t *testing.T) {
+	previousConfig, previousFlags := rootConfig.Template.Capabilities, deployCapabilityFlags
+	t.Cleanup(func() { rootConfig.Template.Capabilities, deployCapabilityFlags = previousConfig, previousFlags })
+
+	rootConfig.Template.Capabilities = []string{"CAPABILITY_IAM"}
+	deployCapabilityFlags = []string{"CAPABILITY_AUTO_EXPAND", "CAPABILITY_IAM"}
+	want := []string{"CAPABILITY_IAM", "CAPABILITY_AUTO_EXPAND"}
+	if diff := cmp.Diff(want, deployCapabilities()); diff != "" {
+		t.Errorf("unexpected capabilities (-want +got):\n%s", diff)
+	}
+}
+
+type fakeDeleteChangeSet struct {
+	deletedChangeSets []string
+	deletedStacks     []string
+}
+
+func (f *fakeDeleteChangeSet) DeleteChangeSet(
+	_ context.Context, input *cloudformation.DeleteChangeSetInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.DeleteChangeSetOutput, error) {
+	f.deletedChangeSets = append(f.deletedChangeSets, aws.ToString(input.ChangeSetName))
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func (f *fakeDeleteChangeSet) DeleteStack(
+	_ context.Context, input *cloudformation.DeleteStackInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.DeleteStackOutput, error) {
+	f.deletedStacks = append(f.deletedStacks, aws.ToString(input.StackName))
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func TestDeleteChangeSet(t *testing.T) {
+	changeSet := &cloudformation.DescribeChangeSetOutput{
+		ChangeSetId: aws.String("change-set-id"),
+		StackId:     aws.String("stack-id"),
+	}
+	testCases := []struct {
+		changeSetType     types.ChangeSetType
+		wantChangeSets    []string
+		wantDeletedStacks []string
+	}{
+		{types.ChangeSetTypeUpdate, []string{"change-set-id"}, nil},
+		{types.ChangeSetTypeCreate, nil, []string{"stack-id"}},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.changeSetType), func(t *testing.T) {
+			client := &fakeDeleteChangeSet{}
+			if err := deleteChangeSet(context.Background(), client, changeSet, tc.changeSetType); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.wantChangeSets, client.deletedChangeSets); diff != "" {
+				t.Errorf("unexpected deleted change sets (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDeletedStacks, client.deletedStacks); diff != "" {
+				t.Errorf("unexpected deleted stacks (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
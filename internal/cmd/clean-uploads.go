@@ -2,14 +2,22 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
@@ -25,23 +33,66 @@ hfc upload configuration but are not in use by any configured stack.
 
 If the S3 bucket for hfc uploads is shared with other projects, and no prefix is
 defined in the hfc upload configuration, clean-uploads may delete unrelated
-objects from the bucket.
+objects from the bucket. To guard against this, clean-uploads refuses to run
+without a prefix when the bucket contains objects that don't look like hfc
+uploads, unless --force is given. The --prefix flag overrides the configured
+prefix for a single run.
+
+The --include and --exclude flags restrict cleanup to objects whose full keys
+match at least one of the provided glob patterns, using the syntax of Go's
+path.Match. Objects that match an exclude pattern are never deleted, even if
+they also match an include pattern.
+
+If versioning is enabled or suspended on the bucket, deleting an object only
+hides it behind a delete marker, and its old versions continue to incur
+storage costs. For these buckets, clean-uploads permanently deletes every
+version of each unused object, along with the versions and delete markers left
+behind by uploads that no longer have a current version, like those deleted by
+older versions of hfc.
+
+Templates that hfc uploaded for deployment are never deleted, since they are
+small and may be shared by several stacks. Use a bucket lifecycle rule to
+expire them.
 
 The command prints the keys of objects to be deleted and requests confirmation
 before proceeding.
 `,
-	PreRun: initializePreRun,
-	Run:    runCleanUploads,
+	PreRunE: initializePreRun,
+	RunE:    runCleanUploads,
 }
 
+var (
+	cleanUploadsInclude []string
+	cleanUploadsExclude []string
+	cleanUploadsPrefix  string
+	cleanUploadsForce   bool
+)
+
 func init() {
+	cleanUploadsCmd.Flags().StringVar(&cleanUploadsPrefix, "prefix", "", "Clean objects under this prefix instead of the configured one")
+	cleanUploadsCmd.Flags().BoolVar(&cleanUploadsForce, "force", false, "Clean a bucket with no prefix even if it appears to be shared")
+	cleanUploadsCmd.Flags().StringArrayVar(&cleanUploadsInclude, "include", nil, "Only delete objects with keys matching this glob (repeatable)")
+	cleanUploadsCmd.Flags().StringArrayVar(&cleanUploadsExclude, "exclude", nil, "Never delete objects with keys matching this glob (repeatable)")
 	rootCmd.AddCommand(cleanUploadsCmd)
 }
 
-func runCleanUploads(cmd *cobra.Command, args []string) {
+func runCleanUploads(cmd *cobra.Command, args []string) error {
+	if err := rootConfig.CheckRequired("upload.bucket"); err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("prefix") {
+		rootConfig.Upload.Prefix = cleanUploadsPrefix
+	}
+	if err := checkAWSAccount(cmd.Context(), sts.NewFromConfig(awsConfig), true); err != nil {
+		return err
+	}
+
 	cfnClient := cloudformation.NewFromConfig(awsConfig)
-	s3Client := s3.NewFromConfig(awsConfig)
-	group, ctx := errgroup.WithContext(context.Background())
+	s3Client, err := newUploadS3Client(cmd.Context(), rootConfig.Upload.Bucket)
+	if err != nil {
+		return err
+	}
+	group, ctx := errgroup.WithContext(cmd.Context())
 	group.SetLimit(5) // TODO: This is arbitrary, is there a specific limit that makes sense?
 
 	var bucketS3Keys []string
@@ -50,85 +101,317 @@ func runCleanUploads(cmd *cobra.Command, args []string) {
 		return
 	})
 
+	var bucketVersions []types.ObjectIdentifier
+	group.Go(func() error {
+		versioned, err := isBucketVersioned(ctx, s3Client)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("unable to check versioning of bucket %s, old versions of deleted objects may remain: %v", rootConfig.Upload.Bucket, err))
+			return nil
+		}
+		if versioned {
+			bucketVersions, err = getUploadedS3Versions(ctx, s3Client)
+		}
+		return err
+	})
+
 	stackS3Keys := make([]string, len(rootConfig.Stacks))
 	for i, stack := range rootConfig.Stacks {
 		group.Go(func() (err error) {
 			stackS3Keys[i], err = getStackS3Key(ctx, cfnClient, stack.Name)
+			// A stack that doesn't exist yet can't be using any uploads.
+			if errors.As(err, new(stackNotFoundError)) {
+				slog.Warn(fmt.Sprintf("skipping %s, which has not been deployed", stack.Name))
+				return nil
+			}
 			return
 		})
 	}
 
 	if err := group.Wait(); err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	bucketS3Keys = lo.Uniq(bucketS3Keys)
-	stackS3Keys = lo.Uniq(stackS3Keys)
+	// Uploaded templates share the prefix with deployment packages. They are
+	// small, and a deployment in progress may still need one, so they're left
+	// for bucket lifecycle rules.
+	bucketS3Keys = slices.DeleteFunc(bucketS3Keys, func(key string) bool {
+		return isTemplateKey(key, rootConfig.Upload.Prefix)
+	})
+
+	if rootConfig.Upload.Prefix == "" && !cleanUploadsForce {
+		if key, ok := lo.Find(bucketS3Keys, func(key string) bool { return !isUploadKey(key, "") }); ok {
+			return fmt.Errorf("bucket %s appears to be shared (found %s), and no prefix is configured; use --prefix, or --force to clean it anyway", rootConfig.Upload.Bucket, key)
+		}
+	}
+
+	currentKeys := bucketS3Keys
+	bucketS3Keys, err = filterKeys(bucketS3Keys, cleanUploadsInclude, cleanUploadsExclude)
+	if err != nil {
+		return err
+	}
+	keepKeys, deleteKeys := planUploadCleanup(bucketS3Keys, lo.Compact(stackS3Keys))
 
-	keepKeys := lo.Intersect(bucketS3Keys, stackS3Keys)
-	deleteKeys, _ := lo.Difference(bucketS3Keys, stackS3Keys)
+	var (
+		removedKeys    []string
+		deleteVersions []types.ObjectIdentifier
+	)
+	if bucketVersions != nil {
+		versionKeys := lo.Uniq(lo.Map(bucketVersions, func(v types.ObjectIdentifier, _ int) string { return aws.ToString(v.Key) }))
+		versionKeys, err = filterKeys(versionKeys, cleanUploadsInclude, cleanUploadsExclude)
+		if err != nil {
+			return err
+		}
+		removedKeys = planRemovedUploadCleanup(versionKeys, currentKeys, lo.Compact(stackS3Keys), rootConfig.Upload.Prefix)
+		deleteVersions = selectVersions(bucketVersions, slices.Concat(deleteKeys, removedKeys))
+	}
 
-	if len(deleteKeys) == 0 {
+	if len(deleteKeys) == 0 && len(removedKeys) == 0 {
 		log.Print("Bucket is clean enough, no objects to delete.")
-		return
+		return nil
 	}
 
 	if len(keepKeys) > 0 {
 		log.Print("Will keep the following in-use objects:\n\n")
 		for _, key := range keepKeys {
-			fmt.Fprintf(log.Writer(), "\t%s\n", key)
+			fmt.Fprintf(os.Stderr, "\t%s\n", key)
 		}
-		fmt.Fprint(log.Writer(), "\n")
+		fmt.Fprint(os.Stderr, "\n")
 	}
 
-	log.Print("Will delete the following unused objects:\n\n")
-	for _, key := range deleteKeys {
-		fmt.Fprintf(log.Writer(), "\t%s\n", key)
+	if len(deleteKeys) > 0 {
+		log.Print("Will delete the following unused objects:\n\n")
+		for _, key := range deleteKeys {
+			fmt.Fprintf(os.Stderr, "\t%s\n", key)
+		}
+		fmt.Fprint(os.Stderr, "\n")
+	}
+	if len(removedKeys) > 0 {
+		log.Print("Will delete all remaining versions of the following previously deleted objects:\n\n")
+		for _, key := range removedKeys {
+			fmt.Fprintf(os.Stderr, "\t%s\n", key)
+		}
+		fmt.Fprint(os.Stderr, "\n")
 	}
-	fmt.Fprint(log.Writer(), "\n"+log.Prefix()+"Press Enter to continue...")
+	if bucketVersions != nil {
+		log.Printf("Bucket %s is versioned, so %d versions and delete markers will be permanently deleted.", rootConfig.Upload.Bucket, len(deleteVersions))
+	}
+	fmt.Fprint(os.Stderr, "[hfc] Press Enter to continue...")
 	fmt.Scanln()
 
-	deleteIdentifiers := make([]types.ObjectIdentifier, len(deleteKeys))
-	for i, key := range deleteKeys {
-		deleteIdentifiers[i] = types.ObjectIdentifier{Key: &key}
+	var deleteErrors []types.Error
+	if bucketVersions != nil {
+		deleteErrors, err = deleteUploadedS3Objects(cmd.Context(), s3Client, deleteVersions)
+	} else {
+		deleteErrors, err = deleteUploadedS3Keys(cmd.Context(), s3Client, deleteKeys)
 	}
-	output, err := s3Client.DeleteObjects(context.Background(), &s3.DeleteObjectsInput{
-		Bucket: aws.String(rootConfig.Upload.Bucket),
-		Delete: &types.Delete{
-			Objects: deleteIdentifiers,
-			Quiet:   aws.Bool(true),
-		},
-	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-
-	if len(output.Errors) > 0 {
-		for _, e := range output.Errors {
-			log.Printf("failed to delete %s: %s", *e.Key, *e.Message)
+	if len(deleteErrors) > 0 {
+		for _, e := range deleteErrors {
+			if versionID := aws.ToString(e.VersionId); versionID != "" {
+				log.Printf("failed to delete %s (version %s): %s", aws.ToString(e.Key), versionID, aws.ToString(e.Message))
+			} else {
+				log.Printf("failed to delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+			}
 		}
-		os.Exit(1)
+		return exitCode(1)
 	}
 
 	log.Print("Deleted all unused objects.")
+	return nil
+}
+
+// s3DeleteObjectsAPI is the subset of the S3 API used to delete uploads.
+type s3DeleteObjectsAPI interface {
+	DeleteObjects(context.Context, *s3.DeleteObjectsInput, ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// planUploadCleanup splits the keys of uploaded packages into those in use by
+// a stack, which must be kept, and those that are safe to delete.
+func planUploadCleanup(bucketKeys, stackKeys []string) (keepKeys, deleteKeys []string) {
+	bucketKeys = lo.Uniq(bucketKeys)
+	stackKeys = lo.Uniq(stackKeys)
+	keepKeys = lo.Intersect(bucketKeys, stackKeys)
+	deleteKeys, _ = lo.Difference(bucketKeys, stackKeys)
+	return
+}
+
+// planRemovedUploadCleanup returns the keys of uploads that have old versions
+// in a versioned bucket, but no current version, and that no stack uses. These
+// are usually uploads that an earlier cleanup deleted without removing their
+// versions. Only keys that look like uploads with the provided prefix are
+// returned, so that old versions of unrelated objects in a shared bucket are
+// left alone.
+func planRemovedUploadCleanup(versionKeys, currentKeys, stackKeys []string, prefix string) []string {
+	return lo.Filter(lo.Uniq(versionKeys), func(key string, _ int) bool {
+		return isUploadKey(key, prefix) && !slices.Contains(currentKeys, key) && !slices.Contains(stackKeys, key)
+	})
+}
+
+// selectVersions returns the versions of objects with the provided keys.
+func selectVersions(versions []types.ObjectIdentifier, keys []string) []types.ObjectIdentifier {
+	return lo.Filter(versions, func(v types.ObjectIdentifier, _ int) bool {
+		return slices.Contains(keys, aws.ToString(v.Key))
+	})
+}
+
+// filterKeys returns the keys that match at least one include pattern, or all
+// keys if there are no include patterns, and that match no exclude patterns.
+// Patterns use the syntax of path.Match.
+func filterKeys(keys, include, exclude []string) ([]string, error) {
+	for _, pattern := range slices.Concat(include, exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	matchAny := func(patterns []string, key string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			matched, _ := path.Match(pattern, key)
+			return matched
+		})
+	}
+	return lo.Filter(keys, func(key string, _ int) bool {
+		return (len(include) == 0 || matchAny(include, key)) && !matchAny(exclude, key)
+	}), nil
+}
+
+// isUploadKey returns true if key has the form of a deployment package that hfc
+// uploaded with the provided prefix.
+func isUploadKey(key, prefix string) bool {
+	_, ok := uploadKeyTime(key, prefix, rootConfig.Upload.KeyFormat)
+	return ok
+}
+
+// uploadKey returns the key for a deployment package uploaded at time t, with
+// the configured prefix and key format.
+func uploadKey(t time.Time) string {
+	var timestamp string
+	if format := rootConfig.Upload.KeyFormat; format != "" {
+		timestamp = t.UTC().Format(format)
+	} else {
+		timestamp = strconv.FormatInt(t.Unix(), 10)
+	}
+	return rootConfig.Upload.Prefix + timestamp + ".zip"
+}
+
+// uploadKeyTime returns the upload time from the key of a deployment package
+// with the provided prefix. Keys may use the key format, if it's set, or a
+// Unix timestamp, which was the only format before the key format was
+// configurable.
+func uploadKeyTime(key, prefix, format string) (time.Time, bool) {
+	name, ok := strings.CutPrefix(key, prefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	timestamp, ok := strings.CutSuffix(name, ".zip")
+	if !ok {
+		return time.Time{}, false
+	}
+	if format != "" {
+		if t, err := time.Parse(format, timestamp); err == nil {
+			return t, true
+		}
+	}
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), true
+	}
+	return time.Time{}, false
 }
 
 // getUploadedS3Keys returns the S3 keys of all Lambda packages currently in the
 // deployment bucket, in the standard order returned by S3.
-//
-// The current implementation is limited to returning 1,000 keys.
-func getUploadedS3Keys(ctx context.Context, s3Client *s3.Client) ([]string, error) {
-	output, err := s3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+func getUploadedS3Keys(ctx context.Context, s3Client s3.ListObjectsV2APIClient) ([]string, error) {
+	keys := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
 		Bucket: aws.String(rootConfig.Upload.Bucket),
 		Prefix: aws.String(rootConfig.Upload.Prefix),
 	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range output.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+// s3GetBucketVersioningAPI is the subset of the S3 API used to check whether
+// the deployment bucket is versioned.
+type s3GetBucketVersioningAPI interface {
+	GetBucketVersioning(context.Context, *s3.GetBucketVersioningInput, ...func(*s3.Options)) (*s3.GetBucketVersioningOutput, error)
+}
+
+// isBucketVersioned returns true if the deployment bucket has versioning
+// enabled, or has had it enabled before it was suspended. Either way, the
+// bucket may hold old versions of objects.
+func isBucketVersioned(ctx context.Context, s3Client s3GetBucketVersioningAPI) (bool, error) {
+	output, err := s3Client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(rootConfig.Upload.Bucket),
+	})
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	return output.Status != "", nil
+}
+
+// getUploadedS3Versions returns the key and version ID of every version and
+// delete marker under the upload prefix in the deployment bucket.
+func getUploadedS3Versions(ctx context.Context, s3Client s3.ListObjectVersionsAPIClient) ([]types.ObjectIdentifier, error) {
+	versions := []types.ObjectIdentifier{}
+	paginator := s3.NewListObjectVersionsPaginator(s3Client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(rootConfig.Upload.Bucket),
+		Prefix: aws.String(rootConfig.Upload.Prefix),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range output.Versions {
+			versions = append(versions, types.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+		}
+		for _, marker := range output.DeleteMarkers {
+			versions = append(versions, types.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+		}
 	}
+	return versions, nil
+}
+
+// maxDeleteObjects is the most objects that S3 can delete in one request.
+const maxDeleteObjects = 1000
 
-	keys := make([]string, len(output.Contents))
-	for i, object := range output.Contents {
-		keys[i] = *object.Key
+// deleteUploadedS3Keys deletes the current versions of the objects with the
+// provided keys from the deployment bucket, and returns any per-object errors
+// reported by S3.
+func deleteUploadedS3Keys(ctx context.Context, s3Client s3DeleteObjectsAPI, keys []string) ([]types.Error, error) {
+	identifiers := make([]types.ObjectIdentifier, len(keys))
+	for i, key := range keys {
+		identifiers[i] = types.ObjectIdentifier{Key: aws.String(key)}
 	}
-	return keys, nil
+	return deleteUploadedS3Objects(ctx, s3Client, identifiers)
+}
+
+// deleteUploadedS3Objects deletes the provided objects from the deployment
+// bucket, and returns any per-object errors reported by S3.
+func deleteUploadedS3Objects(ctx context.Context, s3Client s3DeleteObjectsAPI, identifiers []types.ObjectIdentifier) ([]types.Error, error) {
+	var deleteErrors []types.Error
+	for _, chunk := range lo.Chunk(identifiers, maxDeleteObjects) {
+		output, err := s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(rootConfig.Upload.Bucket),
+			Delete: &types.Delete{
+				Objects: chunk,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		deleteErrors = append(deleteErrors, output.Errors...)
+	}
+	return deleteErrors, nil
 }
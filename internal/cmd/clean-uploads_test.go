@@ -0,0 +1,453 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type fakeS3 struct {
+	objects          []string
+	versions         []types.ObjectVersion
+	deleteMarkers    []types.DeleteMarkerEntry
+	versioningStatus types.BucketVersioningStatus
+	deleteErrors     []types.Error
+	err              error
+
+	// pageSize, if set, limits the number of objects in each page of
+	// ListObjectsV2 results.
+	pageSize int
+
+	listInput   *s3.ListObjectsV2Input
+	listCalls   int
+	deleteInput *s3.DeleteObjectsInput
+	deleteCalls int
+}
+
+func (f *fakeS3) ListObjectsV2(
+	_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options),
+) (*s3.ListObjectsV2Output, error) {
+	f.listInput = input
+	f.listCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	start := 0
+	if token := aws.ToString(input.ContinuationToken); token != "" {
+		var err error
+		if start, err = strconv.Atoi(token); err != nil {
+			return nil, err
+		}
+	}
+	objects := f.objects[start:]
+	output := &s3.ListObjectsV2Output{}
+	if f.pageSize > 0 && len(objects) > f.pageSize {
+		objects = objects[:f.pageSize]
+		output.IsTruncated = aws.Bool(true)
+		output.NextContinuationToken = aws.String(strconv.Itoa(start + f.pageSize))
+	}
+	for _, key := range objects {
+		output.Contents = append(output.Contents, types.Object{Key: aws.String(key)})
+	}
+	return output, nil
+}
+
+func (f *fakeS3) DeleteObjects(
+	_ context.Context, input *s3.DeleteObjectsInput, _ ...func(*s3.Options),
+) (*s3.DeleteObjectsOutput, error) {
+	f.deleteInput = input
+	f.deleteCalls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &s3.DeleteObjectsOutput{Errors: f.deleteErrors}, nil
+}
+
+func (f *fakeS3) GetBucketVersioning(
+	_ context.Context, _ *s3.GetBucketVersioningInput, _ ...func(*s3.Options),
+) (*s3.GetBucketVersioningOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &s3.GetBucketVersioningOutput{Status: f.versioningStatus}, nil
+}
+
+func (f *fakeS3) ListObjectVersions(
+	_ context.Context, _ *s3.ListObjectVersionsInput, _ ...func(*s3.Options),
+) (*s3.ListObjectVersionsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &s3.ListObjectVersionsOutput{Versions: f.versions, DeleteMarkers: f.deleteMarkers}, nil
+}
+
+func setUploadConfig(t *testing.T, bucket, prefix string) {
+	t.Helper()
+	previous := rootConfig.Upload
+	t.Cleanup(func() { rootConfig.Upload = previous })
+	rootConfig.Upload.Bucket = bucket
+	rootConfig.Upload.Prefix = prefix
+}
+
+func TestPlanUploadCleanup(t *testing.T) {
+	testCases := []struct {
+		description string
+		bucketKeys  []string
+		stackKeys   []string
+		wantKeep    []string
+		wantDelete  []string
+	}{{
+		description: "mixed",
+		bucketKeys:  []string{"hfc/1.zip", "hfc/2.zip", "hfc/3.zip"},
+		stackKeys:   []string{"hfc/2.zip"},
+		wantKeep:    []string{"hfc/2.zip"},
+		wantDelete:  []string{"hfc/1.zip", "hfc/3.zip"},
+	}, {
+		description: "shared key",
+		bucketKeys:  []string{"hfc/1.zip", "hfc/2.zip"},
+		stackKeys:   []string{"hfc/2.zip", "hfc/2.zip"},
+		wantKeep:    []string{"hfc/2.zip"},
+		wantDelete:  []string{"hfc/1.zip"},
+	}, {
+		description: "stack key not in bucket",
+		bucketKeys:  []string{"hfc/1.zip"},
+		stackKeys:   []string{"other/1.zip"},
+		wantDelete:  []string{"hfc/1.zip"},
+	}, {
+		description: "undeployed stacks",
+		bucketKeys:  []string{"hfc/1.zip"},
+		stackKeys:   []string{"", ""},
+		wantDelete:  []string{"hfc/1.zip"},
+	}, {
+		description: "everything in use",
+		bucketKeys:  []string{"hfc/1.zip", "hfc/2.zip"},
+		stackKeys:   []string{"hfc/2.zip", "hfc/1.zip"},
+		wantKeep:    []string{"hfc/1.zip", "hfc/2.zip"},
+	}, {
+		description: "empty bucket",
+		stackKeys:   []string{"hfc/1.zip"},
+	}}
+
+	sorted := cmpopts.SortSlices(func(a, b string) bool { return a < b })
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			keep, remove := planUploadCleanup(tc.bucketKeys, tc.stackKeys)
+			if diff := cmp.Diff(tc.wantKeep, keep, sorted, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected keys to keep (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantDelete, remove, sorted, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected keys to delete (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetUploadedS3Keys(t *testing.T) {
+	setUploadConfig(t, "bucket", "hfc/")
+
+	client := &fakeS3{objects: []string{"hfc/1.zip", "hfc/2.zip"}}
+	keys, err := getUploadedS3Keys(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"hfc/1.zip", "hfc/2.zip"}, keys); diff != "" {
+		t.Errorf("unexpected keys (-want +got):\n%s", diff)
+	}
+	if got := aws.ToString(client.listInput.Bucket); got != "bucket" {
+		t.Errorf("unexpected bucket; got %q, want %q", got, "bucket")
+	}
+	if got := aws.ToString(client.listInput.Prefix); got != "hfc/" {
+		t.Errorf("unexpected prefix; got %q, want %q", got, "hfc/")
+	}
+
+	client = &fakeS3{objects: []string{"hfc/1.zip", "hfc/2.zip", "hfc/3.zip", "hfc/4.zip", "hfc/5.zip"}, pageSize: 2}
+	keys, err = getUploadedS3Keys(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(client.objects, keys); diff != "" {
+		t.Errorf("unexpected keys from paginated list (-want +got):\n%s", diff)
+	}
+	if client.listCalls != 3 {
+		t.Errorf("unexpected list calls; got %d, want %d", client.listCalls, 3)
+	}
+
+	client = &fakeS3{err: errors.New("access denied")}
+	if _, err := getUploadedS3Keys(context.Background(), client); err == nil {
+		t.Error("getUploadedS3Keys succeeded despite list error")
+	}
+}
+
+func TestDeleteUploadedS3Keys(t *testing.T) {
+	setUploadConfig(t, "bucket", "hfc/")
+
+	client := &fakeS3{
+		deleteErrors: []types.Error{{Key: aws.String("hfc/2.zip"), Message: aws.String("access denied")}},
+	}
+	deleteErrors, err := deleteUploadedS3Keys(context.Background(), client, []string{"hfc/1.zip", "hfc/2.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleteErrors) != 1 || aws.ToString(deleteErrors[0].Key) != "hfc/2.zip" {
+		t.Errorf("unexpected delete errors: %v", deleteErrors)
+	}
+
+	if got := aws.ToString(client.deleteInput.Bucket); got != "bucket" {
+		t.Errorf("unexpected bucket; got %q, want %q", got, "bucket")
+	}
+	var gotKeys []string
+	for _, object := range client.deleteInput.Delete.Objects {
+		gotKeys = append(gotKeys, aws.ToString(object.Key))
+	}
+	if diff := cmp.Diff([]string{"hfc/1.zip", "hfc/2.zip"}, gotKeys); diff != "" {
+		t.Errorf("unexpected deleted keys (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeleteUploadedS3ObjectsInChunks(t *testing.T) {
+	setUploadConfig(t, "bucket", "hfc/")
+
+	identifiers := make([]types.ObjectIdentifier, maxDeleteObjects+1)
+	for i := range identifiers {
+		identifiers[i] = types.ObjectIdentifier{Key: aws.String("hfc/1.zip"), VersionId: aws.String(strconv.Itoa(i))}
+	}
+	client := &fakeS3{}
+	if _, err := deleteUploadedS3Objects(context.Background(), client, identifiers); err != nil {
+		t.Fatal(err)
+	}
+	if client.deleteCalls != 2 {
+		t.Errorf("unexpected number of delete requests; got %d, want 2", client.deleteCalls)
+	}
+	if got := len(client.deleteInput.Delete.Objects); got != 1 {
+		t.Errorf("unexpected size of last delete request; got %d, want 1", got)
+	}
+}
+
+func TestIsBucketVersioned(t *testing.T) {
+	setUploadConfig(t, "bucket", "hfc/")
+
+	testCases := []struct {
+		status types.BucketVersioningStatus
+		want   bool
+	}{
+		{status: "", want: false},
+		{status: types.BucketVersioningStatusEnabled, want: true},
+		{status: types.BucketVersioningStatusSuspended, want: true},
+	}
+	for _, tc := range testCases {
+		got, err := isBucketVersioned(context.Background(), &fakeS3{versioningStatus: tc.status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("unexpected result for status %q; got %v, want %v", tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestGetUploadedS3Versions(t *testing.T) {
+	setUploadConfig(t, "bucket", "hfc/")
+
+	client := &fakeS3{
+		versions: []types.ObjectVersion{
+			{Key: aws.String("hfc/1.zip"), VersionId: aws.String("a")},
+			{Key: aws.String("hfc/1.zip"), VersionId: aws.String("b")},
+		},
+		deleteMarkers: []types.DeleteMarkerEntry{
+			{Key: aws.String("hfc/2.zip"), VersionId: aws.String("c")},
+		},
+	}
+	got, err := getUploadedS3Versions(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []types.ObjectIdentifier{
+		{Key: aws.String("hfc/1.zip"), VersionId: aws.String("a")},
+		{Key: aws.String("hfc/1.zip"), VersionId: aws.String("b")},
+		{Key: aws.String("hfc/2.zip"), VersionId: aws.String("c")},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(types.ObjectIdentifier{})); diff != "" {
+		t.Errorf("unexpected versions (-want +got):\n%s", diff)
+	}
+}
+
+func TestPlanRemovedUploadCleanup(t *testing.T) {
+	versionKeys := []string{"hfc/1.zip", "hfc/2.zip", "hfc/3.zip", "hfc/notes.txt", "hfc/4.zip"}
+	currentKeys := []string{"hfc/1.zip"}
+	stackKeys := []string{"hfc/4.zip"}
+
+	got := planRemovedUploadCleanup(versionKeys, currentKeys, stackKeys, "hfc/")
+	want := []string{"hfc/2.zip", "hfc/3.zip"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected removed uploads (-want +got):\n%s", diff)
+	}
+
+	versions := []types.ObjectIdentifier{
+		{Key: aws.String("hfc/1.zip"), VersionId: aws.String("a")},
+		{Key: aws.String("hfc/2.zip"), VersionId: aws.String("b")},
+		{Key: aws.String("hfc/4.zip"), VersionId: aws.String("c")},
+	}
+	var gotVersions []string
+	for _, v := range selectVersions(versions, []string{"hfc/1.zip", "hfc/2.zip"}) {
+		gotVersions = append(gotVersions, aws.ToString(v.VersionId))
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, gotVersions); diff != "" {
+		t.Errorf("unexpected selected versions (-want +got):\n%s", diff)
+	}
+}
+
+func TestFilterKeys(t *testing.T) {
+	keys := []string{"hfc/1.zip", "hfc/staging-2.zip", "hfc/keep-staging-3.zip", "other/4.zip"}
+
+	testCases := []struct {
+		description string
+		include     []string
+		exclude     []string
+		want        []string
+		wantErr     bool
+	}{{
+		description: "no patterns",
+		want:        keys,
+	}, {
+		description: "include",
+		include:     []string{"hfc/*staging-*"},
+		want:        []string{"hfc/staging-2.zip", "hfc/keep-staging-3.zip"},
+	}, {
+		description: "exclude",
+		exclude:     []string{"hfc/keep-*"},
+		want:        []string{"hfc/1.zip", "hfc/staging-2.zip", "other/4.zip"},
+	}, {
+		description: "exclude wins over include",
+		include:     []string{"hfc/*"},
+		exclude:     []string{"hfc/keep-*"},
+		want:        []string{"hfc/1.zip", "hfc/staging-2.zip"},
+	}, {
+		description: "multiple includes",
+		include:     []string{"hfc/1.zip", "other/*"},
+		want:        []string{"hfc/1.zip", "other/4.zip"},
+	}, {
+		description: "star does not cross slashes",
+		include:     []string{"*.zip"},
+		want:        []string{},
+	}, {
+		description: "invalid pattern",
+		exclude:     []string{"hfc/["},
+		wantErr:     true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := filterKeys(keys, tc.include, tc.exclude)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected keys (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIsUploadKey(t *testing.T) {
+	testCases := []struct {
+		key    string
+		prefix string
+		want   bool
+	}{
+		{"1700000000.zip", "", true},
+		{"hfc/1700000000.zip", "hfc/", true},
+		{"hfc/1700000000.zip", "", false},
+		{"other/1700000000.zip", "hfc/", false},
+		{"index.html", "", false},
+		{"1700000000.tar.gz", "", false},
+		{".zip", "", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isUploadKey(tc.key, tc.prefix); got != tc.want {
+			t.Errorf("isUploadKey(%q, %q) = %v, want %v", tc.key, tc.prefix, got, tc.want)
+		}
+	}
+}
+
+func TestIsTemplateKey(t *testing.T) {
+	hash := strings.Repeat("0123456789abcdef", 4)
+	testCases := []struct {
+		key    string
+		prefix string
+		want   bool
+	}{
+		{"template-" + hash + ".yaml", "", true},
+		{"hfc/template-" + hash + ".json", "hfc/", true},
+		{"hfc/template-" + hash, "hfc/", true},
+		{"hfc/template-" + hash + ".yaml", "", false},
+		{"template-" + hash[1:] + ".yaml", "", false},
+		{"template-" + strings.ToUpper(hash) + ".yaml", "", false},
+		{"template.yaml", "", false},
+		{"1700000000.zip", "", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isTemplateKey(tc.key, tc.prefix); got != tc.want {
+			t.Errorf("isTemplateKey(%q, %q) = %v, want %v", tc.key, tc.prefix, got, tc.want)
+		}
+	}
+}
+
+func TestUploadKeyTime(t *testing.T) {
+	const format = "20060102-150405"
+	want := time.Date(2025, time.June, 15, 12, 30, 45, 0, time.UTC)
+	testCases := []struct {
+		key    string
+		format string
+		wantOK bool
+	}{
+		{"hfc/20250615-123045.zip", format, true},
+		{"hfc/1749990645.zip", format, true}, // Unix timestamps from before key_format
+		{"hfc/1749990645.zip", "", true},
+		{"hfc/20250615-123045.zip", "", false},
+		{"hfc/template-abc.yaml", format, false},
+		{"other/20250615-123045.zip", format, false},
+	}
+	for _, tc := range testCases {
+		got, ok := uploadKeyTime(tc.key, "hfc/", tc.format)
+		if ok != tc.wantOK || (ok && !got.Equal(want)) {
+			t.Errorf("uploadKeyTime(%q, %q) = (%v, %v), want (%v, %v)", tc.key, tc.format, got, ok, want, tc.wantOK)
+		}
+	}
+}
+
+func TestUploadKey(t *testing.T) {
+	previous := rootConfig.Upload
+	t.Cleanup(func() { rootConfig.Upload = previous })
+	rootConfig.Upload.Prefix = "hfc/"
+
+	uploadTime := time.Date(2025, time.June, 15, 12, 30, 45, 0, time.FixedZone("PDT", -7*60*60))
+	testCases := []struct {
+		format string
+		want   string
+	}{
+		{"", "hfc/1750015845.zip"},
+		{"20060102-150405", "hfc/20250615-193045.zip"},
+	}
+	for _, tc := range testCases {
+		rootConfig.Upload.KeyFormat = tc.format
+		key := uploadKey(uploadTime)
+		if key != tc.want {
+			t.Errorf("unexpected key for format %q; got %q, want %q", tc.format, key, tc.want)
+		}
+		if !isUploadKey(key, "hfc/") {
+			t.Errorf("isUploadKey(%q) = false for format %q", key, tc.format)
+		}
+	}
+}
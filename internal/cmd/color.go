@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+)
+
+var rootNoColor bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&rootNoColor, "no-color", false, "Disable colored output (default $NO_COLOR, or when stdout is not a terminal)")
+}
+
+// color is an ANSI escape sequence that sets the foreground color.
+//
+// Every color has the same length, so that columns of a tabWriter stay aligned
+// as long as each cell in a column is colorized, even if only with
+// colorDefault.
+type color string
+
+const (
+	colorDefault color = "\x1b[39m"
+	colorRed     color = "\x1b[31m"
+	colorGreen   color = "\x1b[32m"
+	colorYellow  color = "\x1b[33m"
+
+	colorReset = "\x1b[0m"
+)
+
+// colorEnabled returns true if output to stdout should be colored.
+func colorEnabled() bool {
+	if rootNoColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	stat, err := os.Stdout.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize returns s in the provided color, if color is enabled.
+func colorize(c color, s string) string {
+	if !colorEnabled() {
+		return s
+	}
+	return string(c) + s + colorReset
+}
+
+// stackStatusColor returns the color for a CloudFormation stack or resource
+// status: red for failures and rollbacks, yellow for operations in progress,
+// and green for successful completion.
+func stackStatusColor(status string) color {
+	switch {
+	case strings.Contains(status, "FAILED") || strings.Contains(status, "ROLLBACK"):
+		return colorRed
+	case strings.HasSuffix(status, "_IN_PROGRESS"):
+		return colorYellow
+	case strings.HasSuffix(status, "_COMPLETE"):
+		return colorGreen
+	default:
+		return colorDefault
+	}
+}
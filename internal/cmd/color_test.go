@@ -0,0 +1,24 @@
+package cmd
+
+import "testing"
+
+func TestStackStatusColor(t *testing.T) {
+	testCases := []struct {
+		status string
+		want   color
+	}{
+		{"CREATE_COMPLETE", colorGreen},
+		{"UPDATE_COMPLETE", colorGreen},
+		{"UPDATE_IN_PROGRESS", colorYellow},
+		{"UPDATE_COMPLETE_CLEANUP_IN_PROGRESS", colorYellow},
+		{"CREATE_FAILED", colorRed},
+		{"ROLLBACK_COMPLETE", colorRed},
+		{"UPDATE_ROLLBACK_IN_PROGRESS", colorRed},
+		{"DELETE_SKIPPED", colorDefault},
+	}
+	for _, tc := range testCases {
+		if got := stackStatusColor(tc.status); got != tc.want {
+			t.Errorf("unexpected color for %s; got %q, want %q", tc.status, got, tc.want)
+		}
+	}
+}
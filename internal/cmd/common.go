@@ -2,26 +2,243 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	"github.com/kballard/go-shellquote"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/shelley"
 )
 
-// getStackS3Key returns the full S3 key (including prefix) for the Lambda
-// package currently in use by the named stack.
-func getStackS3Key(ctx context.Context, cfnClient *cloudformation.Client, stackName string) (string, error) {
-	stack, err := cfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+// stackNotFoundError is the type of error returned when a stack does not exist
+// in CloudFormation.
+type stackNotFoundError struct {
+	StackName string
+}
+
+func (e stackNotFoundError) Error() string {
+	return fmt.Sprintf("stack %s does not exist", e.StackName)
+}
+
+// describeStack returns the description of the named stack. If the stack does
+// not exist, describeStack returns a stackNotFoundError.
+func describeStack(ctx context.Context, cfnClient cloudformation.DescribeStacksAPIClient, stackName string) (types.Stack, error) {
+	output, err := cfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
 		StackName: aws.String(stackName),
 	})
+
+	// CloudFormation doesn't have a dedicated error code for missing stacks, so
+	// this is the best we can do.
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" &&
+		strings.Contains(apiErr.ErrorMessage(), "does not exist") {
+		return types.Stack{}, stackNotFoundError{StackName: stackName}
+	}
 	if err != nil {
-		return "", err
+		return types.Stack{}, err
+	}
+
+	if len(output.Stacks) == 0 {
+		return types.Stack{}, stackNotFoundError{StackName: stackName}
+	}
+	return output.Stacks[0], nil
+}
+
+// isStackStatusStable returns true if the status is not that of an operation
+// in progress, meaning that the stack won't change until another operation
+// starts. A stack under review is stable, since it waits for a change set to
+// be executed.
+func isStackStatusStable(status types.StackStatus) bool {
+	return status == types.StackStatusReviewInProgress ||
+		!strings.HasSuffix(string(status), "_IN_PROGRESS")
+}
+
+// waitForStackStable polls the named stack until its status is stable, logging
+// each status that it observes along the way, and returns its description.
+func waitForStackStable(ctx context.Context, cfnClient cloudformation.DescribeStacksAPIClient, stackName string) (types.Stack, error) {
+	var (
+		stack     types.Stack
+		logStatus = stackStatusLogger(stackName)
+	)
+	err := poll(ctx, defaultPollOptions, func() (done bool, err error) {
+		stack, err = describeStack(ctx, cfnClient, stackName)
+		if err != nil {
+			return false, err
+		}
+		if isStackStatusStable(stack.StackStatus) {
+			return true, nil
+		}
+		logStatus(stack.StackStatus)
+		return false, nil
+	})
+	if err != nil {
+		return types.Stack{}, err
 	}
+	return stack, nil
+}
 
-	for _, p := range stack.Stacks[0].Parameters {
-		if *p.ParameterKey == "CodeS3Key" {
-			return *p.ParameterValue, nil
+// getStackParameter returns the value of the named parameter in the stack's
+// current deployment.
+func getStackParameter(stack types.Stack, key string) (value string, ok bool) {
+	for _, p := range stack.Parameters {
+		if aws.ToString(p.ParameterKey) == key {
+			return aws.ToString(p.ParameterValue), true
 		}
 	}
+	return "", false
+}
+
+// getStackS3Key returns the full S3 key (including prefix) for the Lambda
+// package currently in use by the named stack. If the stack does not exist,
+// getStackS3Key returns a stackNotFoundError.
+func getStackS3Key(ctx context.Context, cfnClient cloudformation.DescribeStacksAPIClient, stackName string) (string, error) {
+	stack, err := describeStack(ctx, cfnClient, stackName)
+	if err != nil {
+		return "", err
+	}
+
+	if key, ok := getStackParameter(stack, "CodeS3Key"); ok {
+		return key, nil
+	}
 	return "", fmt.Errorf("stack %s deployed without CodeS3Key parameter", stackName)
 }
+
+// stackArtifact describes the deployment artifact in use by a stack.
+type stackArtifact struct {
+	Type   string `json:"type"` // "zip" or "image"
+	URI    string `json:"uri"`
+	Bucket string `json:"bucket,omitempty"`
+	Key    string `json:"key,omitempty"`
+}
+
+// getStackArtifact returns the deployment artifact in use by the stack, based on
+// its CodeS3Bucket and CodeS3Key parameters or its ImageUri parameter.
+func getStackArtifact(stack types.Stack) (stackArtifact, error) {
+	if uri, ok := getStackParameter(stack, "ImageUri"); ok {
+		return stackArtifact{Type: "image", URI: uri}, nil
+	}
+
+	bucket, hasBucket := getStackParameter(stack, "CodeS3Bucket")
+	key, hasKey := getStackParameter(stack, "CodeS3Key")
+	if hasBucket && hasKey {
+		return stackArtifact{
+			Type:   "zip",
+			URI:    "s3://" + bucket + "/" + key,
+			Bucket: bucket,
+			Key:    key,
+		}, nil
+	}
+
+	return stackArtifact{}, fmt.Errorf(
+		"stack %s deployed without CodeS3Bucket and CodeS3Key or ImageUri parameters",
+		aws.ToString(stack.StackName))
+}
+
+// stackUnconfigured allows inspection commands to run against a stack that is
+// not in the configuration, like one deployed before the project adopted hfc.
+var stackUnconfigured bool
+
+// addUnconfiguredFlag adds the --unconfigured flag to a command that inspects
+// a single stack.
+func addUnconfiguredFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&stackUnconfigured, "unconfigured", false, "Allow a stack that is not configured, using its name in CloudFormation")
+}
+
+// checkStackConfigured returns an error if no stack with the name is
+// configured, unless the --unconfigured flag was provided.
+func checkStackConfigured(stackName string) error {
+	if _, ok := rootConfig.FindStack(stackName); !ok && !stackUnconfigured {
+		return fmt.Errorf("stack %s is not configured (use --unconfigured to inspect it anyway)", stackName)
+	}
+	return nil
+}
+
+// findStacks returns the configured stacks whose names match any of the
+// patterns, in configuration order. Patterns use the syntax of path.Match, so a
+// plain stack name matches only that stack. It is an error for any pattern to
+// match nothing.
+func findStacks(patterns ...string) ([]config.StackConfig, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid stack pattern %q: %w", pattern, err)
+		}
+	}
+
+	matched := make(map[string]bool)
+	stacks := lo.Filter(rootConfig.Stacks, func(stack config.StackConfig, _ int) bool {
+		found := false
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, stack.Name); ok {
+				matched[pattern] = true
+				found = true
+			}
+		}
+		return found
+	})
+	for _, pattern := range patterns {
+		if !matched[pattern] {
+			return nil, fmt.Errorf("no configured stack matches %s", pattern)
+		}
+	}
+	return stacks, nil
+}
+
+// confirmYes skips the confirmation of changes to protected stacks.
+var confirmYes bool
+
+// confirmStackChange requires the user to type the name of a protected stack
+// before a command changes it, unless the --yes flag was provided.
+func confirmStackChange(stack config.StackConfig) error {
+	if !stack.Protected || confirmYes {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "[hfc] Stack %s is protected. Type its name to continue: ", stack.Name)
+	var input string
+	fmt.Scanln(&input)
+	if input != stack.Name {
+		return fmt.Errorf("confirmation did not match protected stack %s", stack.Name)
+	}
+	return nil
+}
+
+// projectDir returns the directory of the base configuration file, where the
+// state directory lives.
+func projectDir() string {
+	return filepath.Dir(rootState.Path())
+}
+
+// runHooks runs each of the hook commands in the project directory, in order,
+// stopping at the first one that fails. When stackName is non-empty, it is
+// provided to each hook in the HFC_STACK_NAME environment variable.
+func runHooks(hooks []string, stackName string) error {
+	for _, hook := range hooks {
+		args, err := shellquote.Split(hook)
+		if err != nil {
+			return fmt.Errorf("invalid hook %q: %w", hook, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		cmd := shelley.Command(args...).Dir(projectDir())
+		if stackName != "" {
+			cmd.Env("HFC_STACK_NAME", stackName)
+		}
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
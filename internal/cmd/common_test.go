@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/samber/lo"
+
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/state"
+)
+
+type fakeDescribeStacks struct {
+	output *cloudformation.DescribeStacksOutput
+	err    error
+}
+
+func (f fakeDescribeStacks) DescribeStacks(
+	context.Context, *cloudformation.DescribeStacksInput, ...func(*cloudformation.Options),
+) (*cloudformation.DescribeStacksOutput, error) {
+	return f.output, f.err
+}
+
+// fakeStacks implements DescribeStacks for a fixed set of stacks, and reports
+// any other stack as missing in the same way that CloudFormation does.
+type fakeStacks map[string]types.Stack
+
+func (f fakeStacks) DescribeStacks(
+	_ context.Context, input *cloudformation.DescribeStacksInput, _ ...func(*cloudformation.Options),
+) (*cloudformation.DescribeStacksOutput, error) {
+	name := aws.ToString(input.StackName)
+	stack, ok := f[name]
+	if !ok {
+		return nil, &smithy.GenericAPIError{
+			Code:    "ValidationError",
+			Message: "Stack with id " + name + " does not exist",
+		}
+	}
+	return &cloudformation.DescribeStacksOutput{Stacks: []types.Stack{stack}}, nil
+}
+
+func TestGetStackS3Key(t *testing.T) {
+	stackWithParameters := func(parameters ...types.Parameter) *cloudformation.DescribeStacksOutput {
+		return &cloudformation.DescribeStacksOutput{
+			Stacks: []types.Stack{{StackName: aws.String("HFC"), Parameters: parameters}},
+		}
+	}
+
+	testCases := []struct {
+		description  string
+		client       fakeDescribeStacks
+		wantKey      string
+		wantNotFound bool
+		wantErr      bool
+	}{{
+		description: "key present",
+		client: fakeDescribeStacks{output: stackWithParameters(
+			types.Parameter{ParameterKey: aws.String("Environment"), ParameterValue: aws.String("staging")},
+			types.Parameter{ParameterKey: aws.String("CodeS3Key"), ParameterValue: aws.String("hfc/1234.zip")},
+		)},
+		wantKey: "hfc/1234.zip",
+	}, {
+		description: "key missing",
+		client: fakeDescribeStacks{output: stackWithParameters(
+			types.Parameter{ParameterKey: aws.String("Environment"), ParameterValue: aws.String("staging")},
+		)},
+		wantErr: true,
+	}, {
+		description: "nil parameter fields",
+		client: fakeDescribeStacks{output: stackWithParameters(
+			types.Parameter{},
+			types.Parameter{ParameterKey: aws.String("CodeS3Key"), ParameterValue: aws.String("hfc/1234.zip")},
+		)},
+		wantKey: "hfc/1234.zip",
+	}, {
+		description:  "zero stacks",
+		client:       fakeDescribeStacks{output: &cloudformation.DescribeStacksOutput{}},
+		wantNotFound: true,
+		wantErr:      true,
+	}, {
+		description: "stack does not exist",
+		client: fakeDescribeStacks{err: &smithy.GenericAPIError{
+			Code:    "ValidationError",
+			Message: "Stack with id HFC does not exist",
+		}},
+		wantNotFound: true,
+		wantErr:      true,
+	}, {
+		description: "other error",
+		client:      fakeDescribeStacks{err: errors.New("throttled")},
+		wantErr:     true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			key, err := getStackS3Key(context.Background(), tc.client, "HFC")
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			if gotNotFound := errors.As(err, new(stackNotFoundError)); gotNotFound != tc.wantNotFound {
+				t.Errorf("unexpected not found result; got %v, want %v", err, tc.wantNotFound)
+			}
+			if key != tc.wantKey {
+				t.Errorf("unexpected key; got %q, want %q", key, tc.wantKey)
+			}
+		})
+	}
+}
+
+func TestGetStackArtifact(t *testing.T) {
+	parameter := func(key, value string) types.Parameter {
+		return types.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(value)}
+	}
+
+	testCases := []struct {
+		description string
+		parameters  []types.Parameter
+		want        stackArtifact
+		wantErr     bool
+	}{{
+		description: "zip",
+		parameters:  []types.Parameter{parameter("CodeS3Bucket", "hfc"), parameter("CodeS3Key", "lambda/1234.zip")},
+		want:        stackArtifact{Type: "zip", URI: "s3://hfc/lambda/1234.zip", Bucket: "hfc", Key: "lambda/1234.zip"},
+	}, {
+		description: "image",
+		parameters:  []types.Parameter{parameter("ImageUri", "example.com/hfc:1234")},
+		want:        stackArtifact{Type: "image", URI: "example.com/hfc:1234"},
+	}, {
+		description: "key without bucket",
+		parameters:  []types.Parameter{parameter("CodeS3Key", "lambda/1234.zip")},
+		wantErr:     true,
+	}, {
+		description: "neither",
+		wantErr:     true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := getStackArtifact(types.Stack{StackName: aws.String("HFC"), Parameters: tc.parameters})
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("unexpected artifact; got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsStackStatusStable(t *testing.T) {
+	testCases := []struct {
+		status types.StackStatus
+		want   bool
+	}{
+		{types.StackStatusCreateComplete, true},
+		{types.StackStatusUpdateRollbackComplete, true},
+		{types.StackStatusRollbackFailed, true},
+		{types.StackStatusReviewInProgress, true},
+		{types.StackStatusCreateInProgress, false},
+		{types.StackStatusUpdateCompleteCleanupInProgress, false},
+		{types.StackStatusUpdateRollbackInProgress, false},
+	}
+	for _, tc := range testCases {
+		if got := isStackStatusStable(tc.status); got != tc.want {
+			t.Errorf("unexpected result for %s; got %v, want %v", tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestFindStacks(t *testing.T) {
+	previous := rootConfig.Stacks
+	t.Cleanup(func() { rootConfig.Stacks = previous })
+	rootConfig.Stacks = []config.StackConfig{
+		{Name: "svc-use1-prod"},
+		{Name: "svc-use1-staging"},
+		{Name: "svc-usw2-prod"},
+	}
+
+	testCases := []struct {
+		description string
+		patterns    []string
+		want        []string
+		wantErr     bool
+	}{{
+		description: "name",
+		patterns:    []string{"svc-use1-staging"},
+		want:        []string{"svc-use1-staging"},
+	}, {
+		description: "glob",
+		patterns:    []string{"svc-*-prod"},
+		want:        []string{"svc-use1-prod", "svc-usw2-prod"},
+	}, {
+		description: "overlapping globs",
+		patterns:    []string{"svc-usw2-*", "svc-use1-*", "*-prod"},
+		want:        []string{"svc-use1-prod", "svc-use1-staging", "svc-usw2-prod"},
+	}, {
+		description: "no match",
+		patterns:    []string{"svc-*-prod", "svc-*-dev"},
+		wantErr:     true,
+	}, {
+		description: "invalid pattern",
+		patterns:    []string{"svc-["},
+		wantErr:     true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			stacks, err := findStacks(tc.patterns...)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			got := lo.Map(stacks, func(s config.StackConfig, _ int) string { return s.Name })
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected stacks (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckStackConfigured(t *testing.T) {
+	previousStacks, previousUnconfigured := rootConfig.Stacks, stackUnconfigured
+	t.Cleanup(func() { rootConfig.Stacks, stackUnconfigured = previousStacks, previousUnconfigured })
+	rootConfig.Stacks = []config.StackConfig{{Name: "svc-prod"}}
+
+	stackUnconfigured = false
+	if err := checkStackConfigured("svc-prod"); err != nil {
+		t.Errorf("unexpected error for configured stack: %v", err)
+	}
+	if err := checkStackConfigured("legacy-prod"); err == nil {
+		t.Error("checkStackConfigured succeeded for unconfigured stack without --unconfigured")
+	}
+
+	stackUnconfigured = true
+	if err := checkStackConfigured("legacy-prod"); err != nil {
+		t.Errorf("unexpected error for unconfigured stack with --unconfigured: %v", err)
+	}
+}
+
+func TestRunHooksInProjectDir(t *testing.T) {
+	projectDir := t.TempDir()
+	previous := rootState
+	t.Cleanup(func() { rootState = previous })
+	rootState = state.Open(filepath.Join(projectDir, "hfc.toml"))
+
+	// Hooks must run relative to the configuration, even when hfc runs from
+	// another directory.
+	t.Chdir(t.TempDir())
+	if err := runHooks([]string{"touch hook-ran"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "hook-ran")); err != nil {
+		t.Errorf("hook did not run in the project directory: %v", err)
+	}
+}
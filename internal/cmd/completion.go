@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+// completionTimeout bounds the AWS requests made for shell completion, which
+// should fall back to no suggestions rather than hang the shell.
+const completionTimeout = 5 * time.Second
+
+// loadCompletionConfig loads the hfc and AWS configurations for shell
+// completion, without the logging and state directory setup of a full command.
+func loadCompletionConfig(ctx context.Context) (config.Config, aws.Config, error) {
+	opts, err := configLoadOptions()
+	if err != nil {
+		return config.Config{}, aws.Config{}, err
+	}
+	cfg, err := config.Load(opts)
+	if err != nil {
+		return config.Config{}, aws.Config{}, err
+	}
+	cfg.ResolvePaths(filepath.Dir(opts.Path))
+	if rootRegion != "" {
+		cfg.AWS.Region = rootRegion
+	}
+	if rootProfile != "" {
+		cfg.AWS.Profile = rootProfile
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(
+		ctx,
+		awsconfig.WithRegion(cfg.AWS.Region),
+		awsconfig.WithSharedConfigProfile(cfg.AWS.Profile),
+	)
+	return cfg, awsCfg, err
+}
+
+// completeOutputKeys completes a stack name, followed by the key of one of
+// that stack's outputs.
+func completeOutputKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeStackNames(cmd, args, toComplete)
+	}
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	_, awsCfg, err := loadCompletionConfig(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	outputs, err := getStackOutputs(ctx, cloudformation.NewFromConfig(awsCfg), args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var keys []string
+	for _, output := range outputs {
+		if key := aws.ToString(output.OutputKey); strings.HasPrefix(key, toComplete) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeParameterKeys completes a stack name, followed by "Key=" prefixes for
+// the parameters in the stack's configuration and the template's declared
+// parameters that aren't already provided. With deploy --all, it completes only
+// the template's parameters.
+func completeParameterKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var stackName string
+	switch {
+	case deployAll:
+	case len(args) == 0:
+		return completeStackNames(cmd, args, toComplete)
+	default:
+		stackName, args = args[0], args[1:]
+	}
+	if strings.Contains(toComplete, "=") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	cfg, awsCfg, err := loadCompletionConfig(ctx)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var keys []string
+	if stack, ok := cfg.FindStack(stackName); ok {
+		for key := range stack.Parameters {
+			keys = append(keys, key)
+		}
+	}
+	// Templates too large to send inline would have to be uploaded to S3, which
+	// is too much work for completion.
+	if body, err := os.ReadFile(cfg.Template.Path); err == nil && len(body) <= templateBodySizeLimit {
+		summary, err := cloudformation.NewFromConfig(awsCfg).GetTemplateSummary(ctx, &cloudformation.GetTemplateSummaryInput{
+			TemplateBody: aws.String(string(body)),
+		})
+		if err == nil {
+			for _, declaration := range summary.Parameters {
+				keys = append(keys, aws.ToString(declaration.ParameterKey))
+			}
+		}
+	}
+
+	provided := make(map[string]bool)
+	for _, arg := range args {
+		key, _, _ := strings.Cut(arg, "=")
+		provided[key] = true
+	}
+	var completions []string
+	for _, key := range slices.Compact(slices.Sorted(slices.Values(keys))) {
+		if !provided[key] && strings.HasPrefix(key, toComplete) {
+			completions = append(completions, key+"=")
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
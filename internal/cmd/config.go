@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the hfc configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration after merging all files",
+	Long: `Print the effective configuration after merging all files
+
+The show command prints the configuration that other commands use, after
+merging included files and the local configuration, and applying command line
+overrides like --region.
+
+SSM parameter references are shown as written, since the parameters often hold
+secrets. With --show-secrets, the command resolves the references and prints
+the values of the parameters, along with paths made relative to the directory
+of the configuration, exactly as other commands see them.
+`,
+	Args:    cobra.NoArgs,
+	PreRunE: initializeConfigShow,
+	RunE:    runConfigShow,
+}
+
+var (
+	configShowJSON    bool
+	configShowSecrets bool
+)
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowJSON, "json", false, "Print the configuration as JSON instead of TOML")
+	configShowCmd.Flags().BoolVar(&configShowSecrets, "show-secrets", false, "Resolve SSM parameter references and print their values")
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// initializeConfigShow initializes the configuration without resolving SSM
+// parameter references, unless --show-secrets was given.
+func initializeConfigShow(cmd *cobra.Command, args []string) error {
+	return initialize(cmd, true, configShowSecrets)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	if !configShowJSON {
+		encoder := toml.NewEncoder(os.Stdout)
+		encoder.Indent = ""
+		return encoder.Encode(rootConfig)
+	}
+
+	values, err := configValues(rootConfig)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+// configValues returns the configuration as generic values for encoding as
+// JSON. It round trips through TOML, so that JSON keys match the TOML keys that
+// users write in configuration files.
+func configValues(cfg config.Config) (map[string]any, error) {
+	encoded, err := toml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := toml.Unmarshal(encoded, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"runtime"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/shelley"
+)
+
+var consoleCmd = &cobra.Command{
+	Use:               "console [flags] stack",
+	Short:             "Open a CloudFormation stack in the AWS console",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runConsole,
+}
+
+var consolePrint bool
+
+func init() {
+	consoleCmd.Flags().BoolVar(&consolePrint, "print", false, "Print the console URL instead of opening it")
+	addUnconfiguredFlag(consoleCmd)
+	rootCmd.AddCommand(consoleCmd)
+}
+
+func runConsole(cmd *cobra.Command, args []string) error {
+	stackName := args[0]
+	if err := checkStackConfigured(stackName); err != nil {
+		return err
+	}
+	// The console URL names the region in its host name.
+	if awsConfig.Region == "" {
+		return errors.New("no AWS region configured; set aws.region or use --region")
+	}
+
+	stack, err := describeStack(cmd.Context(), cloudformation.NewFromConfig(awsConfig), stackName)
+	if err != nil {
+		return err
+	}
+
+	region := awsConfig.Region
+	consoleURL := fmt.Sprintf(
+		"https://%s.console.aws.amazon.com/cloudformation/home?region=%s#/stacks/stackinfo?stackId=%s",
+		region, url.QueryEscape(region), url.QueryEscape(aws.ToString(stack.StackId)))
+
+	if consolePrint {
+		fmt.Println(consoleURL)
+		return nil
+	}
+
+	var openArgs []string
+	switch runtime.GOOS {
+	case "darwin":
+		openArgs = []string{"open", consoleURL}
+	case "windows":
+		openArgs = []string{"rundll32", "url.dll,FileProtocolHandler", consoleURL}
+	default:
+		openArgs = []string{"xdg-open", consoleURL}
+	}
+	return shelley.Command(openArgs...).Run()
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/spf13/cobra"
+)
+
+var currentPackageCmd = &cobra.Command{
+	Use:   "current-package [flags] stack",
+	Short: "Print the deployment artifact currently in use by a stack",
+	Long: `Print the deployment artifact currently in use by a stack
+
+For stacks deployed from a .zip package, the current-package command prints the
+S3 URL of the package. For stacks deployed from a container image, it prints the
+image URI.
+`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runCurrentPackage,
+}
+
+var currentPackageJSON bool
+
+func init() {
+	currentPackageCmd.Flags().BoolVar(&currentPackageJSON, "json", false, "Print artifact details to stdout as JSON")
+	addUnconfiguredFlag(currentPackageCmd)
+	rootCmd.AddCommand(currentPackageCmd)
+}
+
+func runCurrentPackage(cmd *cobra.Command, args []string) error {
+	stackName := args[0]
+	if err := checkStackConfigured(stackName); err != nil {
+		return err
+	}
+
+	stack, err := describeStack(cmd.Context(), cloudformation.NewFromConfig(awsConfig), stackName)
+	if err != nil {
+		return err
+	}
+	artifact, err := getStackArtifact(stack)
+	if err != nil {
+		return err
+	}
+
+	if !currentPackageJSON {
+		fmt.Println(artifact.URI)
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(artifact)
+}
@@ -1,85 +1,425 @@
 package cmd
 
 import (
+	"context"
 	"errors"
-	"io/fs"
+	"fmt"
 	"log"
 	"os"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/kballard/go-shellquote"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
-	"github.com/featherbread/hfc/internal/shelley"
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/state"
 )
 
 var deployCmd = &cobra.Command{
-	Use:               "deploy [flags] stack [parameters]",
-	Short:             "Deploy the CloudFormation stack with the latest upload",
-	Args:              cobra.MinimumNArgs(1),
-	ValidArgsFunction: completeStackNames,
-	PreRun:            initializePreRun,
-	Run:               runDeploy,
+	Use:   "deploy [flags] {stack | --all} [parameters]",
+	Short: "Deploy the CloudFormation stack with the latest upload",
+	Long: `Deploy the CloudFormation stack with the latest upload
+
+The stack may be a glob pattern, like "svc-*-prod", to deploy every configured
+stack whose name matches, in order. The syntax is that of Go's path.Match.
+
+Parameters for the deployment may follow the stack as Key=Value arguments, or
+be given with the repeatable --parameter flag. Flags take precedence over
+arguments for the same key.
+
+With --github-output or $GITHUB_OUTPUT, deploy writes the stack name, status,
+deployed S3 key, and stack outputs as GitHub Actions step outputs, like
+"stack-status". Each is also written with the stack name as a prefix, like
+"MyStack-stack-status". When several stacks are deployed, only the prefixed
+outputs keep the values of every stack.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if deployAll {
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: completeParameterKeys,
+	PreRunE:           initializePreRun,
+	RunE:              withPhaseTiming("deploy", runDeploy),
 }
 
+var (
+	deployAll             bool
+	deployContinueOnError bool
+	deployTemplateFile    string
+	deployChangeSetOutput string
+	deployCodeBucket      string
+	deployCodeKey         string
+	deployDryRun          bool
+	deployTimeout         time.Duration
+	deployParameters      []string
+	deployCapabilityFlags []string
+)
+
+// deployAppliedChangeSet is set once a deployment applies a change set to a
+// stack, or finds that the stack already matches the deployment.
+var deployAppliedChangeSet bool
+
 func init() {
+	deployCmd.Flags().BoolVar(&deployAll, "all", false, "Deploy to every configured stack, in order")
+	deployCmd.Flags().BoolVarP(&confirmYes, "yes", "y", false, "Do not ask for confirmation before deploying protected stacks")
+	deployCmd.Flags().BoolVar(&deployContinueOnError, "continue-on-error", false, "With --all, keep deploying after a stack fails")
+	deployCmd.Flags().StringVar(&deployTemplateFile, "template-file", "", "Deploy this template instead of the configured one")
+	deployCmd.Flags().StringVar(&deployChangeSetOutput, "changeset-output", "", "Save the change set to this path as JSON before executing it")
+	deployCmd.MarkFlagsMutuallyExclusive("all", "changeset-output")
+	deployCmd.Flags().StringVar(&deployCodeKey, "code-key", "", "Deploy this S3 key instead of the latest upload")
+	deployCmd.Flags().StringVar(&deployCodeBucket, "code-bucket", "", "With --code-key, the bucket containing the key (default: the upload bucket)")
+	deployCmd.Flags().BoolVar(&deployDryRun, "dry-run", false, "Print the stack settings, parameters, and equivalent AWS CLI command without deploying")
+	deployCmd.Flags().DurationVar(&deployTimeout, "timeout", time.Hour, "Maximum time to wait for the stack to finish deploying")
+	deployCmd.MarkFlagsMutuallyExclusive("dry-run", "changeset-output")
+	addParameterFlag(deployCmd)
+	addCapabilityFlag(deployCmd)
+	addTimingsFlag(deployCmd)
 	rootCmd.AddCommand(deployCmd)
 }
 
-func runDeploy(cmd *cobra.Command, args []string) {
-	stackName := args[0]
-	stack, ok := rootConfig.FindStack(stackName)
-	if !ok {
-		log.Fatalf("stack %s is not configured", stackName)
+// addParameterFlag adds the repeatable --parameter flag to a command that
+// resolves deploy parameters.
+func addParameterFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&deployParameters, "parameter", "p", nil, "Set a stack parameter for the deployment, as Key=Value (repeatable)")
+}
+
+// addCapabilityFlag adds the repeatable --capability flag to a command that
+// deploys stacks.
+func addCapabilityFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&deployCapabilityFlags, "capability", nil, "Acknowledge this capability in addition to template.capabilities, like CAPABILITY_IAM (repeatable)")
+}
+
+// cliDeployParameters returns the "Key=Value" parameters from the command line,
+// which are the positional parameters followed by those from --parameter
+// flags. It is an error for any of them to be malformed.
+func cliDeployParameters(positional []string) ([]string, error) {
+	parameters := slices.Concat(positional, deployParameters)
+	for _, p := range parameters {
+		if key, _, ok := strings.Cut(p, "="); !ok || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, must be Key=Value", p)
+		}
+	}
+	return parameters, nil
+}
+
+func runDeploy(cmd *cobra.Command, args []string) error {
+	if deployTemplateFile != "" {
+		if _, err := os.Stat(deployTemplateFile); err != nil {
+			return fmt.Errorf("invalid template file: %w", err)
+		}
+		rootConfig.Template.Path = deployTemplateFile
+	}
+	required := []string{"template.path"}
+	if deployCodeBucket == "" {
+		required = append(required, "upload.bucket")
+	}
+	if err := rootConfig.CheckRequired(required...); err != nil {
+		return err
+	}
+	if err := checkAWSAccount(cmd.Context(), sts.NewFromConfig(awsConfig), false); err != nil {
+		return err
 	}
 
 	lambdaParameters, err := getLambdaPackageParameters()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err := checkCodeBucketRegion(cmd.Context(), lo.CoalesceOrEmpty(deployCodeBucket, rootConfig.Upload.Bucket)); err != nil {
+		return err
 	}
 
-	cliParameters := slices.Clone(args[1:])
-	allParameters := lo.Flatten([][]string{
-		lambdaParameters,
-		cliParameters,
-		lo.MapToSlice(stack.Parameters, func(k, v string) string { return k + "=" + v }),
+	if deployAll {
+		cliParameters, err := cliDeployParameters(args)
+		if err != nil {
+			return err
+		}
+		return deployStacks(cmd.Context(), rootConfig.Stacks, lambdaParameters, cliParameters)
+	}
+
+	cliParameters, err := cliDeployParameters(args[1:])
+	if err != nil {
+		return err
+	}
+	stacks, err := findStacks(args[0])
+	if err != nil {
+		return err
+	}
+	if len(stacks) == 1 {
+		return deployStack(cmd.Context(), stacks[0], lambdaParameters, cliParameters)
+	}
+	if deployChangeSetOutput != "" {
+		return fmt.Errorf("cannot use --changeset-output with %s, which matches %d stacks", args[0], len(stacks))
+	}
+	return deployStacks(cmd.Context(), stacks, lambdaParameters, cliParameters)
+}
+
+// deployStacks deploys each of the stacks in order, then logs a summary of the
+// results.
+func deployStacks(ctx context.Context, stacks []config.StackConfig, lambdaParameters, cliParameters []string) error {
+	var succeeded, failed []string
+	for _, stack := range stacks {
+		log.Printf("Deploying %s", stack.Name)
+		if err := deployStack(ctx, stack, lambdaParameters, cliParameters); err != nil {
+			log.Printf("failed to deploy %s: %v", stack.Name, err)
+			failed = append(failed, stack.Name)
+			if !deployContinueOnError {
+				break
+			}
+			continue
+		}
+		succeeded = append(succeeded, stack.Name)
+	}
+
+	log.Printf("Deployed %d of %d stacks", len(succeeded), len(stacks))
+	if len(succeeded) > 0 {
+		log.Printf("Succeeded: %s", strings.Join(succeeded, ", "))
+	}
+	if len(failed) > 0 {
+		log.Printf("Failed: %s", strings.Join(failed, ", "))
+		if skipped := len(stacks) - len(succeeded) - len(failed); skipped > 0 {
+			log.Printf("Skipped %d remaining stacks after failure", skipped)
+		}
+		return exitCode(1)
+	}
+	return nil
+}
+
+// deployStack deploys the stack with the provided Lambda package parameters,
+// along with any parameters provided on the command line.
+func deployStack(ctx context.Context, stack config.StackConfig, lambdaParameters, cliParameters []string) error {
+	resolvedParameters := resolveDeployParameters(stack, lambdaParameters, cliParameters)
+	allParameters := lo.Map(resolvedParameters, func(p deployParameter, _ int) string { return p.String() })
+
+	if deployDryRun {
+		log.Printf("Dry run for %s, nothing will be deployed", stack.Name)
+		return printDeployDryRun(stack, allParameters)
+	}
+
+	// Problems with the template and parameters are reported before asking
+	// for confirmation or running any hooks, so that nothing happens for a
+	// deployment that can't succeed. CloudFormation can only validate a
+	// template too large to pass inline from S3, so its validation waits
+	// for the upload after confirmation.
+	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	templateBody, err := os.ReadFile(rootConfig.Template.Path)
+	if err != nil {
+		return err
+	}
+	stackPolicy, err := loadStackPolicy(stack)
+	if err != nil {
+		return err
+	}
+	validateParameters := rootConfig.Template.ValidateParameters != ""
+	if validateParameters && len(templateBody) <= templateBodySizeLimit {
+		inline := cfnTemplate{Body: aws.String(string(templateBody))}
+		if err := validateTemplateParameters(ctx, cfnClient, stack, inline, resolvedParameters); err != nil {
+			return err
+		}
+		validateParameters = false
+	}
+
+	if err := confirmStackChange(stack); err != nil {
+		return err
+	}
+	if err := runHooks(rootConfig.Hooks.PreDeploy, stack.Name); err != nil {
+		return err
+	}
+
+	template, err := uploadTemplate(ctx, templateBody)
+	if err != nil {
+		return err
+	}
+	if validateParameters {
+		if err := validateTemplateParameters(ctx, cfnClient, stack, template, resolvedParameters); err != nil {
+			return err
+		}
+	}
+
+	changeSet, changeSetType, err := createChangeSet(ctx, cfnClient, stack, template, allParameters)
+	if err != nil {
+		return fmt.Errorf("creating change set: %w", err)
+	}
+
+	if deployChangeSetOutput != "" {
+		if err := writeChangeSetRecord(changeSet, deployChangeSetOutput); err != nil {
+			return errors.Join(err, deleteChangeSet(ctx, cfnClient, changeSet, changeSetType))
+		}
+		log.Printf("Saved planned change set to %s", deployChangeSetOutput)
+	}
+
+	// A new stack can't take a policy until it exists, but an existing stack
+	// takes its policy before the update, so that the policy protects it.
+	if stackPolicy != "" && changeSetType == cfntypes.ChangeSetTypeUpdate {
+		if err := setStackPolicy(ctx, cfnClient, stack.Name, stackPolicy); err != nil {
+			return errors.Join(err, deleteChangeSet(ctx, cfnClient, changeSet, changeSetType))
+		}
+	}
+
+	if changeSetHasNoChanges(changeSet) {
+		log.Printf("No changes to deploy to %s", stack.Name)
+		deployAppliedChangeSet = true
+		if err := deleteChangeSet(ctx, cfnClient, changeSet, changeSetType); err != nil {
+			return fmt.Errorf("deleting empty change set: %w", err)
+		}
+	} else {
+		deployAppliedChangeSet = true
+		if err := executeChangeSet(ctx, cfnClient, changeSet, changeSetType, deployTimeout); err != nil {
+			return err
+		}
+	}
+
+	if stackPolicy != "" && changeSetType == cfntypes.ChangeSetTypeCreate {
+		if err := setStackPolicy(ctx, cfnClient, stack.Name, stackPolicy); err != nil {
+			return err
+		}
+	}
+
+	if stack.PublishAlias.Alias != "" {
+		if err := publishStackAlias(ctx, stack); err != nil {
+			return fmt.Errorf("publishing alias for %s: %w", stack.Name, err)
+		}
+	}
+
+	logStackOutputs(ctx, stack.Name)
+	if err := writeGitHubStackOutputs(ctx, stack.Name); err != nil {
+		return fmt.Errorf("writing GitHub Actions outputs: %w", err)
+	}
+	return runHooks(rootConfig.Hooks.PostDeploy, stack.Name)
+}
+
+// printDeployDryRun prints the settings that a deployment of the stack with the
+// provided "Key=Value" parameters would use.
+func printDeployDryRun(stack config.StackConfig, parameters []string) error {
+	tw := newTabWriter(os.Stdout)
+	tw.WriteRow("Stack", stack.Name)
+	tw.WriteRow("Template", rootConfig.Template.Path)
+	tw.WriteRow("Region", awsConfig.Region)
+	tw.WriteRow("Capabilities", strings.Join(deployCapabilities(), ", "))
+	tw.WriteRow("Role ARN", lo.CoalesceOrEmpty(stack.RoleARN, rootConfig.Template.RoleARN))
+	tw.WriteRow("Notification ARNs", strings.Join(rootConfig.Template.NotificationARNs, ", "))
+	tw.WriteRow("Stack policy", lo.CoalesceOrEmpty(stack.StackPolicy, rootConfig.Template.StackPolicy))
+	for i, parameter := range parameters {
+		tw.WriteRow(lo.Ternary(i == 0, "Parameters", ""), parameter)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("Equivalent AWS CLI command:")
+	fmt.Println(shellquote.Join(deployCLIArgs(stack, parameters)...))
+	return nil
+}
+
+// deployCLIArgs returns an "aws cloudformation deploy" command line that
+// deploys the stack with the same settings and "Key=Value" parameters as
+// hfc. The AWS CLI has no option for a stack policy, so the command leaves
+// out any configured policy.
+func deployCLIArgs(stack config.StackConfig, parameters []string) []string {
+	args := []string{"aws", "cloudformation", "deploy"}
+	if awsConfig.Region != "" {
+		args = append(args, "--region", awsConfig.Region)
+	}
+	if rootConfig.AWS.Profile != "" {
+		args = append(args, "--profile", rootConfig.AWS.Profile)
+	}
+	args = append(args,
+		"--stack-name", stack.Name,
+		"--template-file", rootConfig.Template.Path,
+		"--no-fail-on-empty-changeset",
+	)
+	if info, err := os.Stat(rootConfig.Template.Path); rootConfig.Template.UseS3 || (err == nil && info.Size() > templateBodySizeLimit) {
+		args = append(args, "--s3-bucket", rootConfig.Upload.Bucket)
+	}
+	if capabilities := deployCapabilities(); len(capabilities) > 0 {
+		args = append(append(args, "--capabilities"), capabilities...)
+	}
+	if roleARN := lo.CoalesceOrEmpty(stack.RoleARN, rootConfig.Template.RoleARN); roleARN != "" {
+		args = append(args, "--role-arn", roleARN)
+	}
+	if len(rootConfig.Template.NotificationARNs) > 0 {
+		args = append(append(args, "--notification-arns"), rootConfig.Template.NotificationARNs...)
+	}
+	if len(parameters) > 0 {
+		args = append(append(args, "--parameter-overrides"), parameters...)
+	}
+	return args
+}
+
+// publishStackAlias publishes a new version of the stack's Lambda function,
+// and points the stack's configured alias at the new version.
+func publishStackAlias(ctx context.Context, stack config.StackConfig) error {
+	publish := stack.PublishAlias
+	outputs, err := getStackOutputs(ctx, cloudformation.NewFromConfig(awsConfig), stack.Name)
+	if err != nil {
+		return err
+	}
+	output, ok := lo.Find(outputs, func(o cfntypes.Output) bool { return aws.ToString(o.OutputKey) == publish.FunctionOutput })
+	if !ok {
+		return fmt.Errorf("stack has no output %s", publish.FunctionOutput)
+	}
+	functionName := aws.ToString(output.OutputValue)
+
+	lambdaClient := lambda.NewFromConfig(awsConfig)
+	version, err := lambdaClient.PublishVersion(ctx, &lambda.PublishVersionInput{
+		FunctionName: aws.String(functionName),
 	})
-	slices.Sort(allParameters)
-
-	deployArgs := lo.Flatten([][]string{
-		{"aws", "cloudformation", "deploy"},
-		lo.Ternary(
-			rootConfig.AWS.Region == "", nil,
-			[]string{"--region", rootConfig.AWS.Region},
-		),
-		{
-			"--template-file", rootConfig.Template.Path,
-			"--stack-name", stackName,
-			"--no-fail-on-empty-changeset",
-		},
-		lo.Ternary(
-			len(rootConfig.Template.Capabilities) == 0, nil,
-			lo.Flatten([][]string{{"--capabilities"}, rootConfig.Template.Capabilities}),
-		),
-		{"--parameter-overrides"},
-		allParameters,
+	if err != nil {
+		return err
+	}
+
+	_, err = lambdaClient.UpdateAlias(ctx, &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(functionName),
+		Name:            aws.String(publish.Alias),
+		FunctionVersion: version.Version,
 	})
-	shelley.ExitIfError(shelley.Command(deployArgs...).Run())
+	if errors.As(err, new(*lambdatypes.ResourceNotFoundException)) {
+		_, err = lambdaClient.CreateAlias(ctx, &lambda.CreateAliasInput{
+			FunctionName:    aws.String(functionName),
+			Name:            aws.String(publish.Alias),
+			FunctionVersion: version.Version,
+		})
+	}
+	if err != nil {
+		return err
+	}
 
-	runOutputs(cmd, args)
+	log.Printf("Published version %s of %s as alias %s", aws.ToString(version.Version), functionName, publish.Alias)
+	return nil
 }
 
+// getLambdaPackageParameters returns the stack parameters that point to the
+// Lambda deployment package, which is the latest upload unless --code-key
+// selects a different one.
 func getLambdaPackageParameters() ([]string, error) {
-	latestPackageRaw, err := os.ReadFile(rootState.LatestLambdaPackagePath())
+	if deployCodeKey != "" {
+		return []string{
+			"CodeS3Bucket=" + lo.CoalesceOrEmpty(deployCodeBucket, rootConfig.Upload.Bucket),
+			"CodeS3Key=" + deployCodeKey,
+		}, nil
+	}
+	if deployCodeBucket != "" {
+		return nil, errors.New("--code-bucket requires --code-key")
+	}
+
+	latestPackage, err := rootState.ReadLatestPackage()
 	switch {
-	case errors.Is(err, fs.ErrNotExist):
+	case errors.Is(err, state.ErrNoLatestPackage):
 		return nil, errors.New("must upload a deployment package before deploying")
 	case err != nil:
 		return nil, err
 	}
 
-	latestPackage := strings.TrimSpace(string(latestPackageRaw))
 	return []string{
 		"CodeS3Bucket=" + rootConfig.Upload.Bucket,
 		"CodeS3Key=" + latestPackage,
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestDeployCLIArgs(t *testing.T) {
+	previousConfig, previousAWSConfig, previousFlags := rootConfig, awsConfig, deployCapabilityFlags
+	t.Cleanup(func() {
+		rootConfig, awsConfig, deployCapabilityFlags = previousConfig, previousAWSConfig, previousFlags
+	})
+
+	rootConfig = config.Config{
+		AWS:    config.AWSConfig{Profile: "staging"},
+		Upload: config.UploadConfig{Bucket: "hfc-uploads"},
+		Template: config.TemplateConfig{
+			Path:             filepath.Join(t.TempDir(), "template.yaml"),
+			Capabilities:     []string{"CAPABILITY_IAM"},
+			RoleARN:          "arn:aws:iam::123456789012:role/default",
+			NotificationARNs: []string{"arn:aws:sns:us-west-2:123456789012:a", "arn:aws:sns:us-west-2:123456789012:b"},
+			UseS3:            true,
+		},
+	}
+	awsConfig = aws.Config{Region: "us-west-2"}
+	deployCapabilityFlags = []string{"CAPABILITY_AUTO_EXPAND"}
+
+	stack := config.StackConfig{Name: "hfc-test", RoleARN: "arn:aws:iam::123456789012:role/stack"}
+	got := deployCLIArgs(stack, []string{"CodeS3Bucket=hfc", "Query=a b"})
+	want := []string{
+		"aws", "cloudformation", "deploy",
+		"--region", "us-west-2",
+		"--profile", "staging",
+		"--stack-name", "hfc-test",
+		"--template-file", rootConfig.Template.Path,
+		"--no-fail-on-empty-changeset",
+		"--s3-bucket", "hfc-uploads",
+		"--capabilities", "CAPABILITY_IAM", "CAPABILITY_AUTO_EXPAND",
+		"--role-arn", "arn:aws:iam::123456789012:role/stack",
+		"--notification-arns", "arn:aws:sns:us-west-2:123456789012:a", "arn:aws:sns:us-west-2:123456789012:b",
+		"--parameter-overrides", "CodeS3Bucket=hfc", "Query=a b",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected command (-want +got):\n%s", diff)
+	}
+}
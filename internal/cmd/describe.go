@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/spf13/cobra"
+)
+
+var describeCmd = &cobra.Command{
+	Use:               "describe [flags] stack",
+	Short:             "Display full details of a CloudFormation stack",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runDescribe,
+}
+
+var describeJSON bool
+
+func init() {
+	describeCmd.Flags().BoolVar(&describeJSON, "json", false, "Print the full stack description to stdout as JSON")
+	addUnconfiguredFlag(describeCmd)
+	rootCmd.AddCommand(describeCmd)
+}
+
+func runDescribe(cmd *cobra.Command, args []string) error {
+	stackName := args[0]
+	if err := checkStackConfigured(stackName); err != nil {
+		return err
+	}
+
+	stack, err := describeStack(cmd.Context(), cloudformation.NewFromConfig(awsConfig), stackName)
+	if err != nil {
+		return err
+	}
+
+	if describeJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stack)
+	}
+
+	tw := newTabWriter(os.Stdout)
+	tw.WriteRow("Name:", aws.ToString(stack.StackName))
+	tw.WriteRow("Status:", colorize(stackStatusColor(string(stack.StackStatus)), string(stack.StackStatus)))
+	if reason := aws.ToString(stack.StackStatusReason); reason != "" {
+		tw.WriteRow("Reason:", reason)
+	}
+	tw.WriteRow("Created:", aws.ToTime(stack.CreationTime).Local().Format(time.DateTime))
+	if stack.LastUpdatedTime != nil {
+		tw.WriteRow("Last updated:", stack.LastUpdatedTime.Local().Format(time.DateTime))
+	}
+	if artifact, err := getStackArtifact(stack); err == nil {
+		tw.WriteRow("Artifact:", artifact.URI)
+	}
+
+	if len(stack.Parameters) > 0 {
+		tw.WriteRow()
+		tw.WriteRow("Parameters:")
+		for _, p := range stack.Parameters {
+			tw.WriteRow("", aws.ToString(p.ParameterKey), aws.ToString(p.ParameterValue))
+		}
+	}
+	if len(stack.Outputs) > 0 {
+		tw.WriteRow()
+		tw.WriteRow("Outputs:")
+		for _, o := range stack.Outputs {
+			tw.WriteRow("", aws.ToString(o.OutputKey), aws.ToString(o.OutputValue))
+		}
+	}
+	if len(stack.Tags) > 0 {
+		tw.WriteRow()
+		tw.WriteRow("Tags:")
+		for _, t := range stack.Tags {
+			tw.WriteRow("", aws.ToString(t.Key), aws.ToString(t.Value))
+		}
+	}
+
+	return tw.Flush()
+}
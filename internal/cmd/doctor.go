@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment for common setup problems",
+	Long: `Check the environment for common setup problems
+
+The doctor command checks that the tools hfc depends on are installed, that AWS
+credentials are available, and that the configured upload bucket and template
+are accessible. Each check is reported as passing or failing, and the command
+exits with a non-zero code if any check fails.
+`,
+	Args:    cobra.NoArgs,
+	PreRunE: initializeReadOnlyPreRun,
+	RunE:    runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+type doctorCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	checks := []doctorCheck{
+		{"go executable", checkExecutable(goBinary())},
+		{"aws credentials", checkAWSCredentials},
+		{"upload bucket", checkUploadBucket},
+		{"template file", checkTemplateFile},
+	}
+
+	failed := false
+	for _, check := range checks {
+		detail, err := check.run(cmd.Context())
+		if err != nil {
+			failed = true
+			fmt.Printf("FAIL  %s: %v\n", check.name, err)
+		} else {
+			fmt.Printf("ok    %s: %s\n", check.name, detail)
+		}
+	}
+
+	if failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+func checkExecutable(name string) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		return exec.LookPath(name)
+	}
+}
+
+func checkAWSCredentials(ctx context.Context) (string, error) {
+	identity, err := sts.NewFromConfig(awsConfig).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s in %s", aws.ToString(identity.Arn), awsConfig.Region), nil
+}
+
+func checkUploadBucket(ctx context.Context) (string, error) {
+	bucket := rootConfig.Upload.Bucket
+	if bucket == "" {
+		return "", errors.New("no bucket configured")
+	}
+	s3Client, err := newUploadS3Client(ctx, rootConfig.Upload.Bucket)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s in %s", bucket, s3Client.Options().Region), nil
+}
+
+func checkTemplateFile(context.Context) (string, error) {
+	path := rootConfig.Template.Path
+	if path == "" {
+		return "", errors.New("no template configured")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift [flags] {stack... | --all}",
+	Short: "Detect resources that have drifted from the CloudFormation template",
+	Long: `Detect resources that have drifted from the CloudFormation template
+
+The drift command runs CloudFormation drift detection against a stack, waits for
+it to complete, and prints each drifted resource along with its property
+differences. It exits with a non-zero code if any drift is detected, so that it
+can gate CI pipelines.
+
+Each stack argument may be a glob pattern, like "svc-*-prod", to check every
+configured stack whose name matches. The syntax is that of Go's path.Match.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if driftAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runDrift,
+}
+
+var driftAll bool
+
+func init() {
+	driftCmd.Flags().BoolVar(&driftAll, "all", false, "Detect drift for every configured stack")
+	rootCmd.AddCommand(driftCmd)
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	var stackNames []string
+	if driftAll {
+		for _, stack := range rootConfig.Stacks {
+			stackNames = append(stackNames, stack.Name)
+		}
+	} else {
+		stacks, err := findStacks(args...)
+		if err != nil {
+			return err
+		}
+		for _, stack := range stacks {
+			stackNames = append(stackNames, stack.Name)
+		}
+	}
+
+	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	group, ctx := errgroup.WithContext(cmd.Context())
+	group.SetLimit(5) // TODO: This is arbitrary, is there a specific limit that makes sense?
+
+	stackDrifts := make([][]types.StackResourceDrift, len(stackNames))
+	for i, stackName := range stackNames {
+		group.Go(func() (err error) {
+			stackDrifts[i], err = detectStackDrift(ctx, cfnClient, stackName)
+			if err != nil {
+				err = fmt.Errorf("detecting drift for %s: %w", stackName, err)
+			}
+			return
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	drifted := false
+	for i, stackName := range stackNames {
+		drifts := stackDrifts[i]
+		if len(drifts) == 0 {
+			fmt.Printf("%s: %s\n", stackName, colorize(colorGreen, "IN_SYNC"))
+			continue
+		}
+
+		drifted = true
+		fmt.Printf("%s: %s\n", stackName, colorize(colorRed, "DRIFTED"))
+		for _, drift := range drifts {
+			fmt.Printf("\t%s (%s): %s\n",
+				aws.ToString(drift.LogicalResourceId),
+				aws.ToString(drift.ResourceType),
+				drift.StackResourceDriftStatus)
+			for _, diff := range drift.PropertyDifferences {
+				fmt.Printf("\t\t%s %s: %s -> %s\n",
+					diff.DifferenceType,
+					aws.ToString(diff.PropertyPath),
+					aws.ToString(diff.ExpectedValue),
+					aws.ToString(diff.ActualValue))
+			}
+		}
+	}
+
+	if drifted {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// detectStackDrift runs drift detection against the named stack, waits for it
+// to complete, and returns the resources that have drifted from the template.
+func detectStackDrift(ctx context.Context, cfnClient *cloudformation.Client, stackName string) ([]types.StackResourceDrift, error) {
+	detection, err := cfnClient.DetectStackDrift(ctx, &cloudformation.DetectStackDriftInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = poll(ctx, defaultPollOptions, func() (bool, error) {
+		status, err := cfnClient.DescribeStackDriftDetectionStatus(ctx, &cloudformation.DescribeStackDriftDetectionStatusInput{
+			StackDriftDetectionId: detection.StackDriftDetectionId,
+		})
+		if err != nil {
+			return false, err
+		}
+		switch status.DetectionStatus {
+		case types.StackDriftDetectionStatusDetectionInProgress:
+			return false, nil
+		case types.StackDriftDetectionStatusDetectionFailed:
+			return false, errors.New(aws.ToString(status.DetectionStatusReason))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []types.StackResourceDrift
+	paginator := cloudformation.NewDescribeStackResourceDriftsPaginator(cfnClient, &cloudformation.DescribeStackResourceDriftsInput{
+		StackName: aws.String(stackName),
+		StackResourceDriftStatusFilters: []types.StackResourceDriftStatus{
+			types.StackResourceDriftStatusModified,
+			types.StackResourceDriftStatusDeleted,
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, page.StackResourceDrifts...)
+	}
+	return drifts, nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:               "events [flags] stack",
+	Short:             "Display recent events for a CloudFormation stack",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runEvents,
+}
+
+var (
+	eventsLimit        int
+	eventsFailuresOnly bool
+)
+
+func init() {
+	eventsCmd.Flags().IntVar(&eventsLimit, "limit", 20, "Maximum number of events to display")
+	eventsCmd.Flags().BoolVar(&eventsFailuresOnly, "failures-only", false, "Display only events with a failed status")
+	addUnconfiguredFlag(eventsCmd)
+	rootCmd.AddCommand(eventsCmd)
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	if eventsLimit < 1 {
+		return fmt.Errorf("--limit must be at least 1, got %d", eventsLimit)
+	}
+	stackName := args[0]
+	if err := checkStackConfigured(stackName); err != nil {
+		return err
+	}
+
+	events, err := getStackEvents(cmd.Context(), cloudformation.NewFromConfig(awsConfig), stackName)
+	if err != nil {
+		return err
+	}
+
+	tw := newTabWriter(os.Stdout)
+
+	for _, event := range events {
+		tw.WriteColumn(aws.ToTime(event.Timestamp).Local().Format(time.DateTime))
+		tw.WriteColumn(aws.ToString(event.LogicalResourceId))
+		status := string(event.ResourceStatus)
+		tw.WriteColumn(colorize(stackStatusColor(status), status))
+		tw.WriteColumn(aws.ToString(event.ResourceStatusReason))
+		tw.EndLine()
+	}
+	return tw.Flush()
+}
+
+// getStackEvents returns up to eventsLimit of the named stack's most recent
+// events, newest first, optionally filtered to failures.
+func getStackEvents(ctx context.Context, cfnClient *cloudformation.Client, stackName string) ([]types.StackEvent, error) {
+	var events []types.StackEvent
+	paginator := cloudformation.NewDescribeStackEventsPaginator(cfnClient, &cloudformation.DescribeStackEventsInput{
+		StackName: aws.String(stackName),
+	})
+	for paginator.HasMorePages() && len(events) < eventsLimit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range page.StackEvents {
+			if eventsFailuresOnly && !strings.HasSuffix(string(event.ResourceStatus), "_FAILED") {
+				continue
+			}
+			events = append(events, event)
+			if len(events) == eventsLimit {
+				break
+			}
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// gitHubOutputPath returns the path to the file that receives GitHub Actions
+// step outputs, or an empty string if hfc is not writing step outputs.
+func gitHubOutputPath() string {
+	if rootGitHubOutput != "" {
+		return rootGitHubOutput
+	}
+	return os.Getenv("GITHUB_OUTPUT")
+}
+
+// writeGitHubStackOutputs writes the name, status, deployed S3 key, and outputs
+// of the named stack as GitHub Actions step outputs. It does nothing if hfc is
+// not writing step outputs.
+//
+// Each value is written twice: under its own name, like "stack-status", and
+// under a name with the stack name as a prefix, like "MyStack-stack-status".
+// When one command deploys several stacks, the unprefixed outputs hold the
+// values for the last stack, while the prefixed outputs keep every stack's.
+func writeGitHubStackOutputs(ctx context.Context, stackName string) error {
+	if gitHubOutputPath() == "" {
+		return nil
+	}
+
+	stack, err := describeStack(ctx, cloudformation.NewFromConfig(awsConfig), stackName)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{
+		"stack-name":   stackName,
+		"stack-status": string(stack.StackStatus),
+	}
+	if key, ok := getStackParameter(stack, "CodeS3Key"); ok {
+		values["code-s3-key"] = key
+	}
+	for _, output := range stack.Outputs {
+		values[aws.ToString(output.OutputKey)] = aws.ToString(output.OutputValue)
+	}
+	return writeGitHubOutputs(withStackOutputNames(stackName, values))
+}
+
+// withStackOutputNames returns the values along with a copy of each one under
+// a name prefixed with the stack name.
+func withStackOutputNames(stackName string, values map[string]string) map[string]string {
+	all := maps.Clone(values)
+	for name, value := range values {
+		all[stackName+"-"+name] = value
+	}
+	return all
+}
+
+// writeGitHubOutputs appends the provided values to the GitHub Actions step
+// output file, in the format described by the GitHub Actions documentation. It
+// does nothing if hfc is not writing step outputs.
+func writeGitHubOutputs(values map[string]string) error {
+	path := gitHubOutputPath()
+	if path == "" || len(values) == 0 {
+		return nil
+	}
+
+	var out strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		value := values[name]
+		if !strings.ContainsAny(value, "\r\n") {
+			fmt.Fprintf(&out, "%s=%s\n", name, value)
+			continue
+		}
+		delimiter := "HFC_" + rand.Text()
+		fmt.Fprintf(&out, "%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(out.String()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
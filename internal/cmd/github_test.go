@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteGitHubOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "github-output")
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	if err := os.WriteFile(path, []byte("existing=value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeGitHubOutputs(map[string]string{
+		"stack-name": "HFC",
+		"Multiline":  "one\ntwo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := regexp.MustCompile(`^existing=value\nMultiline<<(HFC_\w+)\none\ntwo\n(HFC_\w+)\nstack-name=HFC\n$`)
+	match := want.FindSubmatch(got)
+	if match == nil {
+		t.Fatalf("unexpected output file contents:\n%s", got)
+	}
+	if string(match[1]) != string(match[2]) {
+		t.Errorf("mismatched delimiters %q and %q", match[1], match[2])
+	}
+}
+
+func TestWriteGitHubOutputsDisabled(t *testing.T) {
+	t.Setenv("GITHUB_OUTPUT", "")
+	if err := writeGitHubOutputs(map[string]string{"stack-name": "HFC"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithStackOutputNames(t *testing.T) {
+	got := withStackOutputNames("HFCStaging", map[string]string{
+		"stack-status": "UPDATE_COMPLETE",
+		"ApiURL":       "https://example.com",
+	})
+	want := map[string]string{
+		"stack-status":            "UPDATE_COMPLETE",
+		"ApiURL":                  "https://example.com",
+		"HFCStaging-stack-status": "UPDATE_COMPLETE",
+		"HFCStaging-ApiURL":       "https://example.com",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected outputs (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/state"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List recent uploads and the stacks they're deployed to",
+	Long: `List recent uploads and the stacks they're deployed to
+
+The history command lists the deployment packages uploaded from this checkout,
+newest first, with the time of each upload, its S3 key, the version control
+revision that its binary was built from, and its size. Each entry also lists the
+configured stacks that currently deploy it.
+
+History is recorded in the state directory by each upload, so it includes only
+uploads made from this checkout.
+
+The --since flag accepts a duration like "72h", a date like "2006-01-02", or an
+RFC 3339 timestamp.
+`,
+	Args:    cobra.NoArgs,
+	PreRunE: initializeReadOnlyPreRun,
+	RunE:    runHistory,
+}
+
+var (
+	historyLimit int
+	historySince string
+	historyJSON  bool
+)
+
+func init() {
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "Maximum number of uploads to list (0 for no limit)")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "List only uploads after this time or duration ago")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Print history to stdout as a JSON array")
+	rootCmd.AddCommand(historyCmd)
+}
+
+// historyEntry is an upload in the history, along with the stacks that
+// currently deploy it.
+type historyEntry struct {
+	state.UploadRecord
+	Stacks []string `json:"stacks"`
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	var since time.Time
+	if historySince != "" {
+		var err error
+		if since, err = parseSince(historySince, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	records, err := rootState.ReadUploadHistory()
+	if err != nil {
+		return err
+	}
+	records = filterUploadHistory(records, since, historyLimit)
+
+	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	deployments := getStackDeployments(cmd.Context(), cfnClient, rootConfig.Stacks)
+	entries := make([]historyEntry, len(records))
+	for i, record := range records {
+		entries[i] = historyEntry{UploadRecord: record, Stacks: []string{}}
+		for j, stack := range rootConfig.Stacks {
+			if deployments[j].S3Key == record.Key {
+				entries[i].Stacks = append(entries[i].Stacks, stack.Name)
+			}
+		}
+	}
+
+	if historyJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	tw := newTabWriter(os.Stdout)
+	for _, entry := range entries {
+		tw.WriteColumn(entry.Time.Local().Format(time.DateTime))
+		tw.WriteColumn(entry.Key)
+		tw.WriteColumn(shortRevision(entry.Revision))
+		tw.WriteColumn(formatBytes(entry.Size))
+		tw.WriteColumn(strings.Join(entry.Stacks, ", "))
+		tw.EndLine()
+	}
+	return tw.Flush()
+}
+
+// filterUploadHistory returns up to limit of the records uploaded after since,
+// newest first. A zero since or limit doesn't filter.
+func filterUploadHistory(records []state.UploadRecord, since time.Time, limit int) []state.UploadRecord {
+	var result []state.UploadRecord
+	for _, record := range slices.Backward(records) {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		if record.Time.After(since) {
+			result = append(result, record)
+		}
+	}
+	return result
+}
+
+// parseSince parses the value of a --since flag, which may be a duration before
+// now, a date in the local time zone, or an RFC 3339 timestamp.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since value %q: must be a duration, date, or RFC 3339 timestamp", s)
+}
+
+// shortRevision abbreviates a version control revision for display, keeping
+// any "-dirty" suffix.
+func shortRevision(revision string) string {
+	const length = 12
+	if revision == "" {
+		return "(unknown)"
+	}
+	hash, suffix, _ := strings.Cut(revision, "-")
+	if len(hash) > length {
+		hash = hash[:length]
+	}
+	if suffix != "" {
+		return hash + "-" + suffix
+	}
+	return hash
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/samber/lo"
+
+	"github.com/featherbread/hfc/internal/state"
+)
+
+func TestFilterUploadHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var records []state.UploadRecord
+	for i := range 5 {
+		records = append(records, state.UploadRecord{
+			Time: start.Add(time.Duration(i) * time.Hour),
+			Key:  start.Add(time.Duration(i)*time.Hour).Format("15") + ".zip",
+		})
+	}
+
+	testCases := []struct {
+		description string
+		since       time.Time
+		limit       int
+		want        []string
+	}{
+		{"all", time.Time{}, 0, []string{"04.zip", "03.zip", "02.zip", "01.zip", "00.zip"}},
+		{"limit", time.Time{}, 2, []string{"04.zip", "03.zip"}},
+		{"since", start.Add(90 * time.Minute), 0, []string{"04.zip", "03.zip", "02.zip"}},
+		{"since and limit", start.Add(90 * time.Minute), 1, []string{"04.zip"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got := lo.Map(filterUploadHistory(records, tc.since, tc.limit), func(r state.UploadRecord, _ int) string { return r.Key })
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected keys (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "72h", want: now.Add(-72 * time.Hour)},
+		{value: "2025-06-01", want: time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2025-06-01T08:00:00Z", want: time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)},
+		{value: "last week", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseSince(tc.value, now)
+		if gotErr := err != nil; gotErr != tc.wantErr {
+			t.Errorf("unexpected error result for %q; got %v, want error %v", tc.value, err, tc.wantErr)
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("unexpected time for %q; got %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/featherbread/hfc/internal/shelley"
+)
+
+var (
+	rootLogLevel  string
+	rootLogFormat string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&rootLogLevel, "log-level", "info", "Minimum level of log messages to print (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&rootLogFormat, "log-format", "text", "Format of log messages (text, json)")
+
+	// Errors from parsing the command line are logged before configureLogging
+	// runs, so they need a reasonable default.
+	log.SetFlags(0)
+	slog.SetDefault(slog.New(newTextHandler(os.Stderr, slog.LevelInfo)))
+}
+
+// configureLogging sets up the default slog logger based on the logging flags.
+// Messages from the log package are logged at the info level, and commands run
+// with shelley are logged at the debug level. --verbose lowers the level to
+// debug, and --quiet raises it to warn.
+func configureLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(rootLogLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q", rootLogLevel)
+	}
+	if rootVerbose {
+		level = min(level, slog.LevelDebug)
+	}
+	if rootQuiet {
+		level = max(level, slog.LevelWarn)
+	}
+
+	var handler slog.Handler
+	switch rootLogFormat {
+	case "text":
+		handler = newTextHandler(os.Stderr, level)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("invalid log format %q", rootLogFormat)
+	}
+
+	log.SetPrefix("")
+	log.SetFlags(0)
+	slog.SetDefault(slog.New(handler))
+
+	if level <= slog.LevelDebug {
+		debugLogger := slog.NewLogLogger(handler, slog.LevelDebug)
+		debugLogger.SetPrefix("$ ")
+		shelley.DefaultContext.DebugLogger = debugLogger
+	}
+	return nil
+}
+
+// textHandler is a slog.Handler that writes human-readable lines with the hfc
+// prefix, in the style of hfc's original log output. Levels other than info are
+// written before the message, and attributes are written after it.
+type textHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	attrs string
+	group string
+}
+
+func newTextHandler(w io.Writer, level slog.Leveler) *textHandler {
+	return &textHandler{mu: new(sync.Mutex), w: w, level: level}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var line strings.Builder
+	line.WriteString("[hfc] ")
+	if r.Level != slog.LevelInfo {
+		line.WriteString(r.Level.String())
+		line.WriteString(": ")
+	}
+	line.WriteString(r.Message)
+	line.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&line, h.group, a)
+		return true
+	})
+	line.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var line strings.Builder
+	line.WriteString(h.attrs)
+	for _, a := range attrs {
+		h.appendAttr(&line, h.group, a)
+	}
+	h2 := *h
+	h2.attrs = line.String()
+	return &h2
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+func (h *textHandler) appendAttr(line *strings.Builder, group string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			group += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(line, group, ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(line, " %s%s=%s", group, a.Key, value)
+}
@@ -2,13 +2,21 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/featherbread/hfc/internal/config"
@@ -16,16 +24,74 @@ import (
 	"github.com/featherbread/hfc/internal/state"
 )
 
+// Execute runs the hfc command line, and exits with a non-zero code if the
+// command fails.
+//
+// Errors from commands are logged before exiting, except for exitCode errors
+// and errors from subprocesses that already reported their own failures. In
+// those cases, hfc exits silently with the requested code.
+//
+// The first interrupt cancels the context of the running command, so that it
+// can stop waiting on AWS and clean up any partial work. A second interrupt
+// exits immediately.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// Only a signal cancels the context with a cause of its own, rather than
+		// the deferred stop at exit.
+		if context.Cause(ctx) != context.Canceled {
+			log.Print("Interrupted, stopping (interrupt again to exit immediately)")
+		}
+		stop()
+	}()
+
+	var err error
+	if path, ok := findPlugin(os.Args[1:]); ok {
+		err = runPlugin(ctx, path, os.Args[2:])
+	} else {
+		err = rootCmd.ExecuteContext(ctx)
+	}
+	if err == nil {
+		return
+	}
+
+	var (
+		code    exitCode
+		exitErr shelley.ExitError
+	)
+	switch {
+	case errors.As(err, &code):
+		os.Exit(int(code))
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.ExitCode())
+	default:
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 }
 
+// exitCode is an error that makes hfc exit with a specific code, for commands
+// that have already reported the reason for their failure.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return "exit status " + strconv.Itoa(int(c))
+}
+
 var rootCmd = &cobra.Command{
 	Use:     "hfc",
 	Short:   "Build and deploy serverless Go apps with AWS Lambda and CloudFormation",
 	Version: getMainVersion(),
+
+	// Commands log their own errors through Execute. Usage is only worth
+	// printing for errors in the command line itself, which cobra reports
+	// before PersistentPreRun.
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
 }
 
 var (
@@ -34,31 +100,122 @@ var (
 	awsConfig  aws.Config
 )
 
-func initializePreRun(cmd *cobra.Command, args []string) {
-	log.SetPrefix("[hfc] ")
-	log.SetFlags(0)
-	shelley.DefaultContext.DebugLogger = log.New(log.Writer(), "[hfc] $ ", 0)
+var (
+	rootVerbose bool
+	rootQuiet   bool
+	rootRegion  string
+	rootProfile string
 
-	configPath, err := config.FindPath()
-	if err != nil {
-		log.Fatal(err)
+	rootConfigPath    string
+	rootNoLocalConfig bool
+	rootEnv           string
+
+	rootGitHubOutput string
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&rootVerbose, "verbose", "v", false, "Log every command that hfc runs")
+	rootCmd.PersistentFlags().BoolVarP(&rootQuiet, "quiet", "q", false, "Log only warnings and errors")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
+	rootCmd.PersistentFlags().StringVar(&rootRegion, "region", "", "Override the configured AWS region")
+	rootCmd.PersistentFlags().StringVar(&rootProfile, "profile", "", "Override the configured AWS shared config profile")
+	rootCmd.PersistentFlags().StringVarP(&rootConfigPath, "config", "c", "", "Use this configuration file instead of searching for "+config.Filename)
+	rootCmd.PersistentFlags().BoolVar(&rootNoLocalConfig, "no-local-config", false, "Ignore "+config.LocalFilename+" (default $HFC_NO_LOCAL)")
+	rootCmd.PersistentFlags().StringVar(&rootEnv, "env", "", "Merge hfc.<env>.toml over the base configuration (default $HFC_ENV)")
+	rootCmd.PersistentFlags().StringVar(&rootGitHubOutput, "github-output", "", "Write GitHub Actions step outputs to this file (default $GITHUB_OUTPUT)")
+}
+
+// initializePreRun loads the configuration, state, and AWS configuration for
+// a command, creating the state directory if necessary.
+func initializePreRun(cmd *cobra.Command, args []string) error {
+	return initialize(cmd, false, true)
+}
+
+// initializeReadOnlyPreRun is like initializePreRun, for commands that never
+// write to the state directory. It doesn't create the state directory, so that
+// these commands work where the project directory isn't writable.
+func initializeReadOnlyPreRun(cmd *cobra.Command, args []string) error {
+	return initialize(cmd, true, true)
+}
+
+// initialize loads the configuration, state, and AWS configuration. With
+// resolve, it also replaces SSM parameter references in the configuration with
+// the values of the parameters, and makes relative paths in the configuration
+// relative to its directory. Without resolve, the configuration is left as
+// written, for commands that show it to the user or to other programs.
+func initialize(cmd *cobra.Command, readOnly, resolve bool) error {
+	if err := configureLogging(); err != nil {
+		return err
 	}
-	rootConfig, err = config.Load()
+
+	opts, err := configLoadOptions()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	rootState, err = state.Get(configPath)
+	rootConfig, err = config.Load(opts)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if readOnly {
+		rootState = state.Open(opts.Path)
+	} else if rootState, err = state.Get(opts.Path); err != nil {
+		return err
+	}
+
+	if rootRegion != "" {
+		rootConfig.AWS.Region = rootRegion
+	}
+	if rootProfile != "" {
+		rootConfig.AWS.Profile = rootProfile
 	}
 
+	ctx := cmd.Context()
 	awsConfig, err = awsconfig.LoadDefaultConfig(
-		context.Background(),
+		ctx,
 		awsconfig.WithRegion(rootConfig.AWS.Region),
+		awsconfig.WithSharedConfigProfile(rootConfig.AWS.Profile),
 	)
+	if err != nil || !resolve {
+		return err
+	}
+
+	resolver := newSSMResolver(ssm.NewFromConfig(awsConfig))
+	err = rootConfig.ResolveStrings(func(path, value string) (string, error) {
+		return resolver.Resolve(ctx, path, value)
+	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+
+	// Paths in the configuration are relative to its directory, even when hfc
+	// runs elsewhere with --config or from a subdirectory.
+	rootConfig.ResolvePaths(filepath.Dir(opts.Path))
+	return nil
+}
+
+// configLoadOptions returns the options for loading configuration, based on
+// the command line flags and environment. The path in the options is always
+// set, either from the --config flag or by searching for the configuration.
+func configLoadOptions() (config.LoadOptions, error) {
+	var (
+		path = rootConfigPath
+		err  error
+	)
+	if path == "" {
+		path, err = config.FindPath()
+	} else {
+		path, err = filepath.Abs(path)
+	}
+	if err != nil {
+		return config.LoadOptions{}, err
+	}
+
+	noLocal, _ := strconv.ParseBool(os.Getenv("HFC_NO_LOCAL"))
+	return config.LoadOptions{
+		Path:    path,
+		NoLocal: rootNoLocalConfig || noLocal,
+		Env:     lo.CoalesceOrEmpty(rootEnv, os.Getenv("HFC_ENV")),
+	}, nil
 }
 
 func completeStackNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
@@ -66,7 +223,11 @@ func completeStackNames(cmd *cobra.Command, args []string, toComplete string) ([
 		return nil, cobra.ShellCompDirectiveDefault
 	}
 
-	rootConfig, err := config.Load()
+	opts, err := configLoadOptions()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	rootConfig, err := config.Load(opts)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
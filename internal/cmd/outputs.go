@@ -2,43 +2,261 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/kballard/go-shellquote"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/featherbread/hfc/internal/config"
 )
 
 var outputsCmd = &cobra.Command{
-	Use:               "outputs stack",
-	Short:             "Display the outputs for a CloudFormation stack",
-	Args:              cobra.MinimumNArgs(1),
-	ValidArgsFunction: completeStackNames,
-	PreRun:            initializePreRun,
-	Run:               runOutputs,
+	Use:   "outputs [flags] {stack [key] | --all}",
+	Short: "Display the outputs for a CloudFormation stack",
+	Long: `Display the outputs for a CloudFormation stack
+
+By default, the outputs command logs each output's description, key, and value
+in a human-readable format. When a key is provided, the command prints only the
+value of that output to stdout, for use in scripts like:
+
+	export API_URL=$(hfc outputs MyStack ApiUrl)
+
+With --export, the command prints every output as a shell export statement, to
+load all outputs into the environment with:
+
+	eval "$(hfc outputs --export MyStack)"
+
+With --watch, the command first waits for any operation in progress on the
+stack to finish, so that it doesn't print outputs that are about to change.
+
+With --all, the command reads the outputs of every configured stack at once,
+and logs them grouped by stack, or with --json prints a JSON object of stack
+names to outputs. Stacks that have not been deployed are skipped.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if outputsAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	},
+	ValidArgsFunction: completeOutputKeys,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runOutputs,
 }
 
+var (
+	outputsJSON   bool
+	outputsExport bool
+	outputsWatch  bool
+	outputsAll    bool
+)
+
 func init() {
+	outputsCmd.Flags().BoolVar(&outputsJSON, "json", false, "Print outputs to stdout as a JSON object of keys to values")
+	outputsCmd.Flags().BoolVar(&outputsExport, "export", false, "Print outputs to stdout as shell export statements")
+	outputsCmd.MarkFlagsMutuallyExclusive("json", "export")
+	outputsCmd.Flags().BoolVar(&outputsWatch, "watch", false, "Wait for the stack to finish any operation in progress before printing outputs")
+	outputsCmd.Flags().BoolVar(&outputsAll, "all", false, "Display the outputs of every configured stack")
+	outputsCmd.MarkFlagsMutuallyExclusive("all", "export")
+	addUnconfiguredFlag(outputsCmd)
 	rootCmd.AddCommand(outputsCmd)
 }
 
-func runOutputs(cmd *cobra.Command, args []string) {
+func runOutputs(cmd *cobra.Command, args []string) (err error) {
+	if outputsAll {
+		return runAllOutputs(cmd.Context())
+	}
+
 	stackName := args[0]
-	_, ok := rootConfig.FindStack(stackName)
-	if !ok {
-		log.Fatalf("stack %s is not configured", stackName)
+	if err := checkStackConfigured(stackName); err != nil {
+		return err
+	}
+
+	if len(args) > 1 && (outputsJSON || outputsExport) {
+		return errors.New("cannot combine an output key with --json or --export")
+	}
+	defer func() {
+		if err != nil {
+			return
+		}
+		if err = writeGitHubStackOutputs(cmd.Context(), stackName); err != nil {
+			err = fmt.Errorf("writing GitHub Actions outputs: %w", err)
+		}
+	}()
+
+	if outputsWatch {
+		cfnClient := cloudformation.NewFromConfig(awsConfig)
+		if _, err := waitForStackStable(cmd.Context(), cfnClient, stackName); err != nil {
+			return err
+		}
+	}
+
+	if len(args) < 2 && !outputsJSON && !outputsExport {
+		logStackOutputs(cmd.Context(), stackName)
+		return nil
+	}
+
+	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	outputs, err := getStackOutputs(cmd.Context(), cfnClient, stackName)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 1 {
+		key := args[1]
+		output, ok := lo.Find(outputs, func(o types.Output) bool { return aws.ToString(o.OutputKey) == key })
+		if !ok {
+			keys := lo.Map(outputs, func(o types.Output, _ int) string { return aws.ToString(o.OutputKey) })
+			return fmt.Errorf("stack %s has no output %s (available: %s)", stackName, key, strings.Join(keys, ", "))
+		}
+		fmt.Println(aws.ToString(output.OutputValue))
+		return nil
+	}
+
+	if outputsExport {
+		for _, output := range outputs {
+			name := shellIdentifier(aws.ToString(output.OutputKey))
+			fmt.Printf("export %s=%s\n", name, shellquote.Join(aws.ToString(output.OutputValue)))
+		}
+		return nil
 	}
 
+	values := make(map[string]string, len(outputs))
+	for _, output := range outputs {
+		values[aws.ToString(output.OutputKey)] = aws.ToString(output.OutputValue)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+// runAllOutputs displays the outputs of every configured stack, for outputs
+// --all.
+func runAllOutputs(ctx context.Context) error {
 	cfnClient := cloudformation.NewFromConfig(awsConfig)
-	description, err := cfnClient.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{
-		StackName: aws.String(stackName),
-	})
+	stackOutputs, err := getAllStackOutputs(ctx, cfnClient, rootConfig.Stacks)
+	if err != nil {
+		return err
+	}
+
+	if !outputsJSON {
+		for i, stack := range rootConfig.Stacks {
+			if outputs := stackOutputs[i]; outputs != nil {
+				log.Printf("Outputs of %s:", stack.Name)
+				logOutputs(outputs)
+			}
+		}
+		return nil
+	}
+
+	values := make(map[string]map[string]string)
+	for i, stack := range rootConfig.Stacks {
+		if outputs := stackOutputs[i]; outputs != nil {
+			values[stack.Name] = lo.SliceToMap(outputs, func(o types.Output) (string, string) {
+				return aws.ToString(o.OutputKey), aws.ToString(o.OutputValue)
+			})
+		}
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(values)
+}
+
+// getAllStackOutputs returns the outputs of each of the stacks, reading them
+// concurrently. With --watch, it first waits for each stack to be stable.
+// Stacks that don't exist are skipped with a warning, and have nil outputs.
+func getAllStackOutputs(ctx context.Context, cfnClient cloudformation.DescribeStacksAPIClient, stacks []config.StackConfig) ([][]types.Output, error) {
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(5) // TODO: This is arbitrary, is there a specific limit that makes sense?
+	stackOutputs := make([][]types.Output, len(stacks))
+	for i, stack := range stacks {
+		group.Go(func() error {
+			var (
+				description types.Stack
+				err         error
+			)
+			if outputsWatch {
+				description, err = waitForStackStable(ctx, cfnClient, stack.Name)
+			} else {
+				description, err = describeStack(ctx, cfnClient, stack.Name)
+			}
+			if errors.As(err, new(stackNotFoundError)) {
+				slog.Warn(fmt.Sprintf("skipping %s, which has not been deployed", stack.Name))
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			// Outputs are only nil for stacks that were skipped.
+			stackOutputs[i] = description.Outputs
+			if stackOutputs[i] == nil {
+				stackOutputs[i] = []types.Output{}
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return stackOutputs, nil
+}
+
+// logStackOutputs logs the outputs of the named stack in a human-readable
+// format. Failure to read the outputs is logged, but is not fatal.
+func logStackOutputs(ctx context.Context, stackName string) {
+	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	outputs, err := getStackOutputs(ctx, cfnClient, stackName)
 	if err != nil {
 		log.Print("unable to read stack info, will skip printing output")
 		return
 	}
+	logOutputs(outputs)
+}
+
+// logOutputs logs stack outputs in a human-readable format.
+func logOutputs(outputs []types.Output) {
+	for _, output := range outputs {
+		key, value := aws.ToString(output.OutputKey), aws.ToString(output.OutputValue)
+		if description := aws.ToString(output.Description); description != "" {
+			log.Printf("%s (%s):\n\t%s", description, key, value)
+		} else {
+			log.Printf("%s:\n\t%s", key, value)
+		}
+	}
+}
+
+// getStackOutputs returns the outputs of the named stack.
+func getStackOutputs(ctx context.Context, cfnClient *cloudformation.Client, stackName string) ([]types.Output, error) {
+	stack, err := describeStack(ctx, cfnClient, stackName)
+	if err != nil {
+		return nil, err
+	}
+	return stack.Outputs, nil
+}
 
-	for _, output := range description.Stacks[0].Outputs {
-		log.Printf("%s (%s):\n\t%s", *output.Description, *output.OutputKey, *output.OutputValue)
+// shellIdentifier converts s into a valid shell variable name, by replacing
+// any invalid characters with underscores.
+func shellIdentifier(s string) string {
+	if s == "" {
+		return "_"
+	}
+	identifier := []byte(s)
+	for i, c := range identifier {
+		valid := c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			identifier[i] = '_'
+		}
 	}
+	return string(identifier)
 }
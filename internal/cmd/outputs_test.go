@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestShellIdentifier(t *testing.T) {
+	testCases := []struct {
+		in, want string
+	}{
+		{"ApiUrl", "ApiUrl"},
+		{"API_URL_2", "API_URL_2"},
+		{"2ndApi", "_ndApi"},
+		{"api-url.v2", "api_url_v2"},
+		{"", "_"},
+	}
+	for _, tc := range testCases {
+		if got := shellIdentifier(tc.in); got != tc.want {
+			t.Errorf("shellIdentifier(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestGetAllStackOutputs(t *testing.T) {
+	cfnClient := fakeStacks{
+		"svc-prod": {Outputs: []types.Output{{OutputKey: aws.String("ApiUrl"), OutputValue: aws.String("https://prod")}}},
+		"svc-dev":  {},
+	}
+	stacks := []config.StackConfig{{Name: "svc-prod"}, {Name: "svc-staging"}, {Name: "svc-dev"}}
+
+	got, err := getAllStackOutputs(context.Background(), cfnClient, stacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]types.Output{
+		{{OutputKey: aws.String("ApiUrl"), OutputValue: aws.String("https://prod")}},
+		nil,
+		{},
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreUnexported(types.Output{})); diff != "" {
+		t.Errorf("unexpected outputs (-want +got):\n%s", diff)
+	}
+}
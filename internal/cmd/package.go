@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var packageCmd = &cobra.Command{
+	Use:   "package",
+	Short: "Build a Lambda deployment package for the latest build without uploading it",
+	Long: `Build a Lambda deployment package for the latest build without uploading it
+
+The package command builds the same deployment package that upload would, but
+only saves it locally with --output, or lists its contents with --inspect. It
+makes no AWS calls, so it's useful for debugging the package layout.
+`,
+	PreRunE: initializePreRun,
+	RunE:    runPackage,
+}
+
+var (
+	packageOutput  string
+	packageInspect bool
+)
+
+func init() {
+	packageCmd.Flags().StringVar(&packageOutput, "output", "", "Save the deployment package to this path")
+	packageCmd.Flags().BoolVar(&packageInspect, "inspect", false, "Print the name, mode, and size of each file in the package to stdout")
+	packageCmd.Flags().StringVar(&buildOutputDir, "output-dir", "", "Read the binary from this directory instead of the state directory")
+	rootCmd.AddCommand(packageCmd)
+}
+
+func runPackage(cmd *cobra.Command, args []string) error {
+	if err := rootConfig.CheckRequired("project.name"); err != nil {
+		return err
+	}
+	outputPath, err := binaryPath()
+	if err != nil {
+		return err
+	}
+
+	lambdaPackage, err := buildLambdaPackage(outputPath, packageOutput)
+	if err != nil {
+		return fmt.Errorf("failed to create deployment package: %w", err)
+	}
+	defer lambdaPackage.Close()
+
+	log.Printf("Deployment package is %s", formatBytes(lambdaPackage.Size))
+	if packageOutput != "" {
+		log.Printf("Saved deployment package to %s", packageOutput)
+	}
+	if !packageInspect {
+		return nil
+	}
+
+	// Packages are always built in memory or in a file, both of which support
+	// random access for reading them back.
+	readerAt, ok := lambdaPackage.Body.(io.ReaderAt)
+	if !ok {
+		return errors.New("deployment package does not support random access")
+	}
+	zipReader, err := zip.NewReader(readerAt, lambdaPackage.Size)
+	if err != nil {
+		return fmt.Errorf("reading deployment package: %w", err)
+	}
+	return printLambdaPackageEntries(os.Stdout, zipReader.File)
+}
+
+// printLambdaPackageEntries writes the mode, size, compressed size, and name of
+// each file in a deployment package to w.
+func printLambdaPackageEntries(w io.Writer, files []*zip.File) error {
+	tw := newTabWriter(w)
+	for _, file := range files {
+		tw.WriteRow(
+			file.Mode().String(),
+			strconv.FormatUint(file.UncompressedSize64, 10),
+			strconv.FormatUint(file.CompressedSize64, 10),
+			file.Name,
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestPrintLambdaPackageEntries(t *testing.T) {
+	dir := t.TempDir()
+	handlerPath := filepath.Join(dir, "handler")
+	if err := os.WriteFile(handlerPath, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	noticePath := filepath.Join(dir, "NOTICE")
+	if err := os.WriteFile(noticePath, []byte("hfc\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	upload := config.UploadConfig{
+		Compression: "store",
+		ExtraFiles:  []config.PackageFileConfig{{Source: noticePath, ArchivePath: "NOTICE"}},
+	}
+	if err := createLambdaPackage(&buf, handlerPath, upload); err != nil {
+		t.Fatal(err)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := printLambdaPackageEntries(&out, zipReader.File); err != nil {
+		t.Fatal(err)
+	}
+	want := "-rwxr-xr-x  10  10  bootstrap\n-rw-------  4   4   NOTICE\n"
+	if got := out.String(); got != want {
+		t.Errorf("unexpected output; got %q, want %q", got, want)
+	}
+}
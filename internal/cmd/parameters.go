@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+var parametersCmd = &cobra.Command{
+	Use:   "parameters [flags] stack [parameters]",
+	Short: "Print the parameters that deploy would send to a stack",
+	Long: `Print the parameters that deploy would send to a stack
+
+The parameters command prints the value of every parameter that deploy would
+send for the stack with the same arguments, along with the source of the
+value. In increasing order of precedence, the sources are:
+
+  package       the bucket and key of the latest upload
+  config        the stack's parameters in the hfc configuration, and the
+                LayerArns parameter for any configured Lambda layers
+  command line  Key=Value arguments after the stack name, then --parameter
+                flags
+`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeParameterKeys,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runParameters,
+}
+
+var parametersJSON bool
+
+func init() {
+	parametersCmd.Flags().BoolVar(&parametersJSON, "json", false, "Print parameters to stdout as a JSON array")
+	addParameterFlag(parametersCmd)
+	rootCmd.AddCommand(parametersCmd)
+}
+
+func runParameters(cmd *cobra.Command, args []string) error {
+	stackName := args[0]
+	stack, ok := rootConfig.FindStack(stackName)
+	if !ok {
+		return fmt.Errorf("stack %s is not configured", stackName)
+	}
+
+	cliParameters, err := cliDeployParameters(args[1:])
+	if err != nil {
+		return err
+	}
+	lambdaParameters, err := getLambdaPackageParameters()
+	if err != nil {
+		return err
+	}
+	parameters := resolveDeployParameters(stack, lambdaParameters, cliParameters)
+
+	if parametersJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(parameters)
+	}
+
+	tw := newTabWriter(os.Stdout)
+	for _, parameter := range parameters {
+		tw.WriteRow(parameter.Key, parameter.Value, "("+parameter.Source+")")
+	}
+	return tw.Flush()
+}
+
+// Sources of deploy parameters, in increasing order of precedence.
+const (
+	parameterSourcePackage     = "package"
+	parameterSourceConfig      = "config"
+	parameterSourceCommandLine = "command line"
+)
+
+// deployParameter is the value of a stack parameter for a deployment, along
+// with the source that provided it.
+type deployParameter struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// String returns the parameter in "Key=Value" form.
+func (p deployParameter) String() string {
+	return p.Key + "=" + p.Value
+}
+
+// layerARNsParameter is the stack parameter that receives the configured Lambda
+// layers, as a comma-separated list suitable for a CommaDelimitedList.
+const layerARNsParameter = "LayerArns"
+
+// stackLayerARNs returns the ARNs of the Lambda layers for the stack, which
+// are the template's layers followed by the stack's own.
+func stackLayerARNs(stack config.StackConfig) []string {
+	return lo.Uniq(slices.Concat(rootConfig.Template.Layers, stack.Layers))
+}
+
+// resolveDeployParameters merges the parameters for a deployment of the stack
+// from the "Key=Value" parameters for the Lambda package, the stack's
+// configuration, and the "Key=Value" parameters from the command line, in
+// increasing order of precedence. The result is sorted by key.
+//
+// Configured Lambda layers are passed in the LayerArns parameter, unless the
+// stack's parameters set it explicitly.
+func resolveDeployParameters(stack config.StackConfig, lambdaParameters, cliParameters []string) []deployParameter {
+	resolved := make(map[string]deployParameter)
+	addAll := func(source string, parameters []string) {
+		for _, p := range parameters {
+			key, value, _ := strings.Cut(p, "=")
+			resolved[key] = deployParameter{Key: key, Value: value, Source: source}
+		}
+	}
+
+	addAll(parameterSourcePackage, lambdaParameters)
+	if layers := stackLayerARNs(stack); len(layers) > 0 {
+		addAll(parameterSourceConfig, []string{layerARNsParameter + "=" + strings.Join(layers, ",")})
+	}
+	addAll(parameterSourceConfig, lo.MapToSlice(stack.Parameters, func(k, v string) string { return k + "=" + v }))
+	addAll(parameterSourceCommandLine, cliParameters)
+
+	return slices.SortedFunc(maps.Values(resolved), func(a, b deployParameter) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+}
+
+// validateTemplateParameters checks the resolved parameters for a deployment
+// of the stack against the parameters that the template declares,
+// and either logs or returns the problems that it finds, as configured.
+//
+// CloudFormation parses the template, so that the check understands the same
+// YAML and JSON syntax as a deployment.
+func validateTemplateParameters(ctx context.Context, cfnClient *cloudformation.Client, stack config.StackConfig, template cfnTemplate, parameters []deployParameter) error {
+	summary, err := cfnClient.GetTemplateSummary(ctx, &cloudformation.GetTemplateSummaryInput{
+		TemplateBody: template.Body,
+		TemplateURL:  template.URL,
+	})
+	if err != nil {
+		return fmt.Errorf("validating parameters: %w", err)
+	}
+
+	current, err := describeStack(ctx, cfnClient, stack.Name)
+	if err != nil && !errors.As(err, new(stackNotFoundError)) {
+		return err
+	}
+	hasPrevious := func(key string) bool {
+		_, ok := getStackParameter(current, key)
+		return ok
+	}
+
+	problems := templateParameterProblems(summary.Parameters, parameters, hasPrevious)
+	if len(problems) == 0 {
+		return nil
+	}
+	if rootConfig.Template.ValidateParameters == "warn" {
+		for _, problem := range problems {
+			slog.Warn(problem)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid parameters for %s:\n\t%s", stack.Name, strings.Join(problems, "\n\t"))
+}
+
+// templateParameterProblems describes parameters that a template doesn't
+// declare, and declared parameters with no default that are neither provided
+// nor have a previous value for the stack to keep.
+func templateParameterProblems(declarations []types.ParameterDeclaration, parameters []deployParameter, hasPrevious func(key string) bool) []string {
+	var problems []string
+	for _, p := range parameters {
+		declared := slices.ContainsFunc(declarations, func(d types.ParameterDeclaration) bool {
+			return aws.ToString(d.ParameterKey) == p.Key
+		})
+		if !declared {
+			problems = append(problems, fmt.Sprintf("parameter %s from %s is not declared by the template", p.Key, p.Source))
+		}
+	}
+	for _, d := range declarations {
+		key := aws.ToString(d.ParameterKey)
+		provided := slices.ContainsFunc(parameters, func(p deployParameter) bool { return p.Key == key })
+		if d.DefaultValue == nil && !provided && !hasPrevious(key) {
+			problems = append(problems, fmt.Sprintf("template parameter %s has no value", key))
+		}
+	}
+	return problems
+}
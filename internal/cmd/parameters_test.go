@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestResolveDeployParameters(t *testing.T) {
+	stack := config.StackConfig{
+		Name: "HFCStaging",
+		Parameters: map[string]string{
+			"Environment": "staging",
+			"CodeS3Key":   "override.zip",
+		},
+	}
+	lambdaParameters := []string{"CodeS3Bucket=hfc", "CodeS3Key=1234.zip"}
+	cliParameters := []string{"Environment=preview", "Query=a=b"}
+
+	got := resolveDeployParameters(stack, lambdaParameters, cliParameters)
+	want := []deployParameter{
+		{Key: "CodeS3Bucket", Value: "hfc", Source: parameterSourcePackage},
+		{Key: "CodeS3Key", Value: "override.zip", Source: parameterSourceConfig},
+		{Key: "Environment", Value: "preview", Source: parameterSourceCommandLine},
+		{Key: "Query", Value: "a=b", Source: parameterSourceCommandLine},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolveDeployParametersLayers(t *testing.T) {
+	previous := rootConfig.Template.Layers
+	t.Cleanup(func() { rootConfig.Template.Layers = previous })
+	rootConfig.Template.Layers = []string{"arn:aws:lambda:us-west-2:123456789012:layer:shared:3"}
+
+	testCases := []struct {
+		description string
+		stack       config.StackConfig
+		want        deployParameter
+	}{{
+		description: "template layers",
+		stack:       config.StackConfig{Name: "HFCStaging"},
+		want: deployParameter{
+			Key:    "LayerArns",
+			Value:  "arn:aws:lambda:us-west-2:123456789012:layer:shared:3",
+			Source: parameterSourceConfig,
+		},
+	}, {
+		description: "stack layers",
+		stack: config.StackConfig{
+			Name:   "HFCStaging",
+			Layers: []string{"arn:aws:lambda:us-west-2:123456789012:layer:extension:1"},
+		},
+		want: deployParameter{
+			Key:    "LayerArns",
+			Value:  "arn:aws:lambda:us-west-2:123456789012:layer:shared:3,arn:aws:lambda:us-west-2:123456789012:layer:extension:1",
+			Source: parameterSourceConfig,
+		},
+	}, {
+		description: "explicit parameter",
+		stack: config.StackConfig{
+			Name:       "HFCStaging",
+			Parameters: map[string]string{"LayerArns": ""},
+		},
+		want: deployParameter{Key: "LayerArns", Value: "", Source: parameterSourceConfig},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got := resolveDeployParameters(tc.stack, nil, nil)
+			if diff := cmp.Diff([]deployParameter{tc.want}, got); diff != "" {
+				t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemplateParameterProblems(t *testing.T) {
+	declarations := []types.ParameterDeclaration{
+		{ParameterKey: aws.String("CodeS3Bucket")},
+		{ParameterKey: aws.String("CodeS3Key")},
+		{ParameterKey: aws.String("Environment")},
+		{ParameterKey: aws.String("LogLevel"), DefaultValue: aws.String("info")},
+		{ParameterKey: aws.String("DomainName")},
+	}
+	parameters := []deployParameter{
+		{Key: "CodeS3Bucket", Value: "hfc", Source: parameterSourcePackage},
+		{Key: "CodeS3Key", Value: "1234.zip", Source: parameterSourcePackage},
+		{Key: "Enviroment", Value: "staging", Source: parameterSourceConfig},
+	}
+	hasPrevious := func(key string) bool { return key == "DomainName" }
+
+	got := templateParameterProblems(declarations, parameters, hasPrevious)
+	want := []string{
+		"parameter Enviroment from config is not declared by the template",
+		"template parameter Environment has no value",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected problems (-want +got):\n%s", diff)
+	}
+}
+
+func TestCLIDeployParameters(t *testing.T) {
+	previous := deployParameters
+	t.Cleanup(func() { deployParameters = previous })
+
+	testCases := []struct {
+		description string
+		positional  []string
+		flags       []string
+		want        []string
+		wantErr     bool
+	}{
+		{"none", nil, nil, nil, false},
+		{"positional", []string{"Environment=staging"}, nil, []string{"Environment=staging"}, false},
+		{"flags after positional", []string{"Environment=staging"}, []string{"Environment=preview", "Query=a=b"}, []string{"Environment=staging", "Environment=preview", "Query=a=b"}, false},
+		{"empty value", nil, []string{"DomainName="}, []string{"DomainName="}, false},
+		{"missing equals", nil, []string{"Environment"}, nil, true},
+		{"missing key", []string{"=staging"}, nil, nil, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			deployParameters = tc.flags
+			got, err := cliDeployParameters(tc.positional)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
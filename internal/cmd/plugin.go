@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/shelley"
+)
+
+// pluginPrefix is the prefix of the names of external commands, which extend
+// hfc with commands of their own. Running "hfc <name>" runs "hfc-<name>" from
+// the PATH when hfc has no built-in command with that name.
+const pluginPrefix = "hfc-"
+
+// findPlugin returns the path to the external command that the arguments
+// invoke, if the first argument names a command that hfc doesn't have and an
+// executable for it is in the PATH.
+func findPlugin(args []string) (path string, ok bool) {
+	if len(args) == 0 || args[0] == "" || strings.HasPrefix(args[0], "-") {
+		return "", false
+	}
+	// cobra adds the help command when it executes, so Find doesn't know it.
+	if args[0] == "help" {
+		return "", false
+	}
+	if _, _, err := rootCmd.Find(args); err == nil {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + args[0])
+	return path, err == nil
+}
+
+// runPlugin runs the external command at path with the arguments.
+//
+// If hfc can find a configuration, the command receives the path to it in the
+// HFC_CONFIG_PATH environment variable, the path to the state directory in
+// HFC_STATE_DIR, and in HFC_CONFIG_JSON_PATH, the path to a temporary file with
+// the full configuration as JSON, in the same form as "hfc config show --json".
+// As with config show, SSM parameter references are left unresolved, so that
+// secrets don't leak to the command.
+//
+// The global flags of hfc apply only to built-in commands, but the environment
+// variables that set their defaults, like HFC_ENV, apply to the configuration
+// that the command receives.
+func runPlugin(ctx context.Context, path string, args []string) error {
+	cmd := shelley.Command(append([]string{path}, args...)...)
+
+	if opts, err := configLoadOptions(); err == nil {
+		rootCmd.SetContext(ctx)
+		if err := initialize(rootCmd, true, false); err != nil {
+			return err
+		}
+		configPath, err := writePluginConfig(rootConfig)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(configPath)
+		cmd.Env("HFC_CONFIG_PATH", opts.Path).
+			Env("HFC_STATE_DIR", rootState.Path()).
+			Env("HFC_CONFIG_JSON_PATH", configPath)
+	}
+
+	return cmd.Run()
+}
+
+// writePluginConfig writes the configuration as JSON to a new temporary file,
+// and returns the path to the file. A file, unlike an environment variable, has
+// no size limit, and leaves the plugin's standard input free for the user.
+func writePluginConfig(cfg config.Config) (string, error) {
+	values, err := configValues(cfg)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp("", "hfc-config-*.json")
+	if err != nil {
+		return "", err
+	}
+	err = json.NewEncoder(file).Encode(values)
+	if err = errors.Join(err, file.Close()); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestFindPlugin(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"hfc-hello", "hfc-status"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	testCases := []struct {
+		args     []string
+		wantPath string
+	}{
+		{[]string{"hello", "--flag"}, filepath.Join(dir, "hfc-hello")},
+		{[]string{"status"}, ""}, // built-in commands take precedence
+		{[]string{"missing"}, ""},
+		{[]string{"--verbose", "hello"}, ""},
+		{nil, ""},
+	}
+	for _, tc := range testCases {
+		path, ok := findPlugin(tc.args)
+		if path != tc.wantPath || ok != (tc.wantPath != "") {
+			t.Errorf("unexpected result for %q; got (%q, %v), want %q", tc.args, path, ok, tc.wantPath)
+		}
+	}
+}
+
+func TestWritePluginConfig(t *testing.T) {
+	cfg := config.Config{
+		Project: config.ProjectConfig{Name: "hfc"},
+		Upload:  config.UploadConfig{Bucket: "ssm:/hfc/bucket"},
+	}
+	path, err := writePluginConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(path) })
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Project config.ProjectConfig `json:"project"`
+		Upload  struct {
+			Bucket string `json:"bucket"`
+		} `json:"upload"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Project.Name != "hfc" {
+		t.Errorf("unexpected project name; got %q, want %q", got.Project.Name, "hfc")
+	}
+	if got.Upload.Bucket != "ssm:/hfc/bucket" {
+		t.Errorf("unexpected bucket; got %q, want %q", got.Upload.Bucket, "ssm:/hfc/bucket")
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// pollOptions controls how often poll checks for completion, and for how long.
+type pollOptions struct {
+	// MinInterval is the delay after the first check, which doubles after each
+	// following check up to MaxInterval.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// MaxDuration limits the total time spent polling. Zero means no limit
+	// other than the context's.
+	MaxDuration time.Duration
+}
+
+// defaultPollOptions suit CloudFormation operations, which can finish in
+// seconds or take most of an hour, without polling often enough to risk
+// throttling in accounts with many deployments.
+var defaultPollOptions = pollOptions{
+	MinInterval: 2 * time.Second,
+	MaxInterval: 15 * time.Second,
+}
+
+// pollTimeoutError is returned by poll when it gives up after the maximum
+// duration.
+type pollTimeoutError time.Duration
+
+func (e pollTimeoutError) Error() string {
+	return "timed out after " + time.Duration(e).String()
+}
+
+// poll calls check until it reports that it's done or returns an error,
+// waiting between calls with exponential backoff. Callers surface progress by
+// logging from check.
+func poll(ctx context.Context, opts pollOptions, check func() (done bool, err error)) error {
+	var deadline <-chan time.Time
+	if opts.MaxDuration > 0 {
+		timer := time.NewTimer(opts.MaxDuration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	interval := opts.MinInterval
+	for {
+		done, err := check()
+		if done || err != nil {
+			return err
+		}
+		select {
+		case <-time.After(interval):
+		case <-deadline:
+			return pollTimeoutError(opts.MaxDuration)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		interval = min(interval*2, opts.MaxInterval)
+	}
+}
+
+// stackStatusLogger returns a function that logs the status of the named stack
+// whenever it changes, to show progress while waiting on the stack.
+func stackStatusLogger(stackName string) func(types.StackStatus) {
+	var lastStatus types.StackStatus
+	return func(status types.StackStatus) {
+		if status != lastStatus {
+			log.Printf("Waiting for %s (%s)", stackName, status)
+			lastStatus = status
+		}
+	}
+}
+
+// stackWaiterRetryable is the type of the function that an SDK waiter for a
+// stack uses to decide whether to keep waiting.
+type stackWaiterRetryable = func(context.Context, *cloudformation.DescribeStacksInput, *cloudformation.DescribeStacksOutput, error) (bool, error)
+
+// logStackWaiterStatus wraps the retryable function of an SDK waiter for a
+// stack to log the stack's status whenever it changes.
+func logStackWaiterStatus(stackName string, retryable stackWaiterRetryable) stackWaiterRetryable {
+	logStatus := stackStatusLogger(stackName)
+	return func(ctx context.Context, input *cloudformation.DescribeStacksInput, output *cloudformation.DescribeStacksOutput, err error) (bool, error) {
+		if err == nil && len(output.Stacks) > 0 {
+			logStatus(output.Stacks[0].StackStatus)
+		}
+		return retryable(ctx, input, output, err)
+	}
+}
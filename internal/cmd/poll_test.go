@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	opts := pollOptions{MinInterval: time.Millisecond, MaxInterval: 4 * time.Millisecond}
+
+	var checks int
+	err := poll(context.Background(), opts, func() (bool, error) {
+		checks++
+		return checks == 3, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks != 3 {
+		t.Errorf("unexpected number of checks; got %d, want 3", checks)
+	}
+
+	checkErr := errors.New("stack failed")
+	err = poll(context.Background(), opts, func() (bool, error) { return false, checkErr })
+	if !errors.Is(err, checkErr) {
+		t.Errorf("unexpected error from failed check; got %v, want %v", err, checkErr)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	opts := pollOptions{MinInterval: time.Millisecond, MaxInterval: time.Millisecond, MaxDuration: 20 * time.Millisecond}
+	err := poll(context.Background(), opts, func() (bool, error) { return false, nil })
+	if !errors.As(err, new(pollTimeoutError)) {
+		t.Errorf("unexpected error after max duration; got %v, want pollTimeoutError", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = poll(ctx, pollOptions{MinInterval: time.Hour}, func() (bool, error) { return false, nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error after cancellation; got %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// ssmReferencePrefix marks a configuration value as the name of an SSM
+// Parameter Store parameter whose value replaces it.
+const ssmReferencePrefix = "ssm:"
+
+// ssmGetParameterAPI is the subset of the SSM API used to resolve references.
+type ssmGetParameterAPI interface {
+	GetParameter(context.Context, *ssm.GetParameterInput, ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// ssmResolver resolves configuration values that reference SSM parameters,
+// fetching each parameter at most once.
+type ssmResolver struct {
+	client ssmGetParameterAPI
+	cache  map[string]string
+}
+
+func newSSMResolver(client ssmGetParameterAPI) *ssmResolver {
+	return &ssmResolver{client: client, cache: make(map[string]string)}
+}
+
+// Resolve returns the value of the SSM parameter that the configuration value
+// at path references, or the value itself if it isn't a reference.
+//
+// The AWS settings can't reference parameters, since they're needed to load
+// the AWS configuration that fetches the parameters.
+func (r *ssmResolver) Resolve(ctx context.Context, path, value string) (string, error) {
+	name, ok := strings.CutPrefix(value, ssmReferencePrefix)
+	if !ok {
+		return value, nil
+	}
+	if strings.HasPrefix(path, "aws.") {
+		return "", fmt.Errorf("%s: AWS settings can't reference SSM parameters", path)
+	}
+	if cached, ok := r.cache[name]; ok {
+		return cached, nil
+	}
+
+	output, err := r.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: fetching SSM parameter %s: %w", path, name, err)
+	}
+	resolved := aws.ToString(output.Parameter.Value)
+	r.cache[name] = resolved
+	return resolved, nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+type fakeSSM struct {
+	parameters map[string]string
+	calls      int
+}
+
+func (f *fakeSSM) GetParameter(_ context.Context, input *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	f.calls++
+	value, ok := f.parameters[aws.ToString(input.Name)]
+	if !ok {
+		return nil, &types.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{
+		Parameter: &types.Parameter{Name: input.Name, Value: aws.String(value)},
+	}, nil
+}
+
+func TestSSMResolver(t *testing.T) {
+	client := &fakeSSM{parameters: map[string]string{"/hfc/bucket": "hfc-staging"}}
+	resolver := newSSMResolver(client)
+	ctx := context.Background()
+
+	testCases := []struct {
+		path, value string
+		want        string
+		wantErr     bool
+	}{
+		{path: "upload.bucket", value: "ssm:/hfc/bucket", want: "hfc-staging"},
+		{path: "stacks.parameters.Bucket", value: "ssm:/hfc/bucket", want: "hfc-staging"},
+		{path: "upload.prefix", value: "lambda/", want: "lambda/"},
+		{path: "upload.kms_key_id", value: "ssm:/hfc/missing", wantErr: true},
+		{path: "aws.profile", value: "ssm:/hfc/profile", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := resolver.Resolve(ctx, tc.path, tc.value)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("unexpected error for %s; got %v, want error %v", tc.path, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("unexpected value for %s; got %q, want %q", tc.path, got, tc.want)
+		}
+	}
+
+	if client.calls != 2 {
+		t.Errorf("unexpected GetParameter calls; got %d, want 2", client.calls)
+	}
+}
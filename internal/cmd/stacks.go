@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var stacksCmd = &cobra.Command{
+	Use:     "stacks",
+	Short:   "List configured stacks and their CloudFormation status",
+	Args:    cobra.NoArgs,
+	PreRunE: initializeReadOnlyPreRun,
+	RunE:    runStacks,
+}
+
+var stacksJSON bool
+
+func init() {
+	stacksCmd.Flags().BoolVar(&stacksJSON, "json", false, "Print stacks to stdout as a JSON array")
+	rootCmd.AddCommand(stacksCmd)
+}
+
+type stackSummary struct {
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"`
+	Status string `json:"status,omitempty"`
+}
+
+func runStacks(cmd *cobra.Command, args []string) error {
+	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	group, ctx := errgroup.WithContext(cmd.Context())
+	group.SetLimit(5) // TODO: This is arbitrary, is there a specific limit that makes sense?
+
+	summaries := make([]stackSummary, len(rootConfig.Stacks))
+	for i, stack := range rootConfig.Stacks {
+		group.Go(func() error {
+			summaries[i].Name = stack.Name
+			description, err := describeStack(ctx, cfnClient, stack.Name)
+			if errors.As(err, new(stackNotFoundError)) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			summaries[i].Exists = true
+			summaries[i].Status = string(description.StackStatus)
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	if stacksJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+
+	tw := newTabWriter(os.Stdout)
+
+	for _, summary := range summaries {
+		tw.WriteColumn(summary.Name)
+		if summary.Exists {
+			tw.WriteColumn(colorize(stackStatusColor(summary.Status), summary.Status))
+		} else {
+			tw.WriteColumn("(not deployed)")
+		}
+		tw.EndLine()
+	}
+	return tw.Flush()
+}
@@ -1,121 +1,188 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
-	"io/fs"
-	"log"
+	"fmt"
+	"io"
 	"os"
-	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
+
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/state"
 )
 
 var statusCmd = &cobra.Command{
-	Use:    "status",
-	Short:  "Summarize the deployment status of all stacks",
-	PreRun: initializePreRun,
-	Run:    runStatus,
+	Use:   "status [flags] [stack...]",
+	Short: "Summarize the deployment status of all stacks",
+	Long: `Summarize the deployment status of all stacks
+
+When stack arguments are provided, the status command only reports on the
+matching stacks. Each argument may be a glob pattern, like "svc-*-prod", using
+the syntax of Go's path.Match.
+`,
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializeReadOnlyPreRun,
+	RunE:              runStatus,
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
 }
 
-func runStatus(cmd *cobra.Command, args []string) {
-	const (
-		minwidth = 1
-		tabwidth = 8
-		padding  = 2
-		padchar  = ' '
-		flags    = 0
-	)
-	tw := tabWriter{
-		Writer: tabwriter.NewWriter(os.Stdout, minwidth, tabwidth, padding, padchar, flags),
-	}
-	defer func() {
-		if err := tw.Flush(); err != nil {
-			log.Fatal(err)
+func runStatus(cmd *cobra.Command, args []string) error {
+	stacks := rootConfig.Stacks
+	if len(args) > 0 {
+		var err error
+		if stacks, err = findStacks(args...); err != nil {
+			return err
 		}
-	}()
+	}
 
-	latestPackageRaw, err := os.ReadFile(rootState.LatestLambdaPackagePath())
-	latestPackage := strings.TrimSpace(string(latestPackageRaw))
+	tw := newTabWriter(os.Stdout)
+
+	latestPackage, err := rootState.ReadLatestPackage()
 	switch {
 	default:
 		tw.WriteColumn("(build)")
 		tw.WriteColumn(latestPackage)
 		tw.EndLine()
-	case errors.Is(err, fs.ErrNotExist):
+	case errors.Is(err, state.ErrNoLatestPackage):
 		tw.WriteColumn("(build)")
 		tw.WriteColumn("(none)")
 		tw.EndLine()
 	case err != nil:
-		log.Fatal(err)
+		return err
 	}
 
-	if len(rootConfig.Stacks) == 0 {
-		return
+	if len(stacks) == 0 {
+		return tw.Flush()
 	}
 
+	now := time.Now()
 	cfnClient := cloudformation.NewFromConfig(awsConfig)
+	deployments := getStackDeployments(cmd.Context(), cfnClient, stacks)
+	for i, stack := range stacks {
+		tw.WriteColumn(stack.Name)
+
+		deployment := deployments[i]
+		if deployment.S3Key == "" {
+			tw.WriteColumn("(unknown)")
+			tw.WriteColumn(colorize(colorDefault, ""))
+		} else {
+			currency := packageCurrency(deployment.S3Key, latestPackage)
+			tw.WriteColumn(deployment.S3Key)
+			tw.WriteColumn(colorize(lo.Ternary(deployment.S3Key == latestPackage, colorGreen, colorYellow), currency))
+		}
+		if !deployment.UpdatedTime.IsZero() {
+			tw.WriteColumn(formatTimeAgo(deployment.UpdatedTime, now))
+		}
+		tw.EndLine()
+	}
+	return tw.Flush()
+}
+
+// stackDeployment describes the latest deployment of a stack.
+type stackDeployment struct {
+	// S3Key is the key of the deployed package, or empty if it can't be
+	// determined.
+	S3Key string
+	// UpdatedTime is the time of the stack's last update or creation, or zero
+	// if the stack can't be described.
+	UpdatedTime time.Time
+}
+
+// getStackDeployments describes the latest deployment of each of the stacks.
+//
+// Errors here are intentionally not hard failures. One misconfigured or
+// not-yet-deployed stack should not prevent reporting for other stacks.
+func getStackDeployments(ctx context.Context, cfnClient cloudformation.DescribeStacksAPIClient, stacks []config.StackConfig) []stackDeployment {
 	var group errgroup.Group
 	group.SetLimit(5) // TODO: This is arbitrary, is there a specific limit that makes sense?
-	stackS3Keys := make([]string, len(rootConfig.Stacks))
-	for i, stack := range rootConfig.Stacks {
+	deployments := make([]stackDeployment, len(stacks))
+	for i, stack := range stacks {
 		group.Go(func() error {
-			// Errors here are intentionally not hard failures. One misconfigured or
-			// not-yet-deployed stack should not prevent reporting for other stacks.
-			if key, err := getStackS3Key(context.Background(), cfnClient, stack.Name); err == nil {
-				stackS3Keys[i] = key
+			description, err := describeStack(ctx, cfnClient, stack.Name)
+			if err != nil {
+				return nil
 			}
+			deployments[i].S3Key, _ = getStackParameter(description, "CodeS3Key")
+			deployments[i].UpdatedTime = aws.ToTime(lo.CoalesceOrEmpty(description.LastUpdatedTime, description.CreationTime))
 			return nil
 		})
 	}
 	group.Wait()
+	return deployments
+}
 
-	for i, stack := range rootConfig.Stacks {
-		tw.WriteColumn(stack.Name)
-
-		key := stackS3Keys[i]
-		if key == "" {
-			tw.WriteColumn("(unknown)")
-			tw.EndLine()
-			continue
-		}
+// packageCurrency describes whether a stack's deployed package is the latest
+// upload.
+func packageCurrency(stackKey, latestKey string) string {
+	if stackKey == latestKey {
+		return "(current)"
+	}
+	return "(not-current)"
+}
 
-		tw.WriteColumn(key)
-		if key == latestPackage {
-			tw.WriteColumn("(current)")
-		} else {
-			tw.WriteColumn("(not-current)")
-		}
-		tw.EndLine()
+// formatTimeAgo describes the time t relative to now, in the largest whole
+// unit of days, hours, or minutes.
+func formatTimeAgo(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
 	}
 }
 
+// newTabWriter returns a tabWriter that aligns columns written to w.
+func newTabWriter(w io.Writer) *tabWriter {
+	const (
+		minwidth = 1
+		tabwidth = 8
+		padding  = 2
+		padchar  = ' '
+		flags    = 0
+	)
+	b := &tabWriter{out: w}
+	b.Writer = tabwriter.NewWriter(&b.buf, minwidth, tabwidth, padding, padchar, flags)
+	return b
+}
+
+// tabWriter wraps a tabwriter.Writer with methods to write cells and rows.
+//
+// A tabWriter renders the whole table in memory, and writes it to the
+// underlying writer in a single call when flushed. Rendering can't fail
+// partway through, so errors come only from Flush, and a table is never left
+// half written by an error in the middle of rendering it. A tabwriter.Writer
+// can't be used at all after an error from its own writer.
 type tabWriter struct {
 	*tabwriter.Writer
+	out    io.Writer
+	buf    bytes.Buffer
 	inLine bool
-	err    error
-}
-
-func (b *tabWriter) Write(buf []byte) (n int, err error) {
-	if b.err != nil {
-		return 0, b.err
-	}
-	n, b.err = b.Writer.Write(buf)
-	return n, b.err
 }
 
+// Flush aligns the table written so far, and writes it to the underlying
+// writer.
 func (b *tabWriter) Flush() error {
-	if b.err != nil {
-		return b.err
-	}
-	return b.Writer.Flush()
+	b.Writer.Flush() // Writes to a bytes.Buffer can't fail.
+	_, err := b.out.Write(b.buf.Bytes())
+	b.buf.Reset()
+	return err
 }
 
 func (b *tabWriter) WriteColumn(s string) error {
@@ -124,11 +191,18 @@ func (b *tabWriter) WriteColumn(s string) error {
 	}
 	b.Write([]byte(s))
 	b.inLine = true
-	return b.err
+	return nil
+}
+
+func (b *tabWriter) WriteRow(columns ...string) error {
+	for _, column := range columns {
+		b.WriteColumn(column)
+	}
+	return b.EndLine()
 }
 
 func (b *tabWriter) EndLine() error {
 	b.Write([]byte("\n"))
 	b.inLine = false
-	return b.err
+	return nil
 }
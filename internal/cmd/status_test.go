@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestGetStackDeployments(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	client := fakeStacks{
+		"Current": {
+			StackName:       aws.String("Current"),
+			CreationTime:    aws.Time(created),
+			LastUpdatedTime: aws.Time(updated),
+			Parameters: []types.Parameter{
+				{ParameterKey: aws.String("CodeS3Key"), ParameterValue: aws.String("hfc/2.zip")},
+			},
+		},
+		"Outdated": {
+			StackName:    aws.String("Outdated"),
+			CreationTime: aws.Time(created),
+			Parameters: []types.Parameter{
+				{ParameterKey: aws.String("CodeS3Key"), ParameterValue: aws.String("hfc/1.zip")},
+			},
+		},
+		"NoKey": {StackName: aws.String("NoKey"), CreationTime: aws.Time(created)},
+	}
+	stacks := []config.StackConfig{
+		{Name: "Current"},
+		{Name: "Missing"},
+		{Name: "Outdated"},
+		{Name: "NoKey"},
+	}
+
+	got := getStackDeployments(context.Background(), client, stacks)
+	want := []stackDeployment{
+		{S3Key: "hfc/2.zip", UpdatedTime: updated},
+		{},
+		{S3Key: "hfc/1.zip", UpdatedTime: created},
+		{UpdatedTime: created},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected deployments (-want +got):\n%s", diff)
+	}
+}
+
+func TestPackageCurrency(t *testing.T) {
+	testCases := []struct {
+		description string
+		stackKey    string
+		latestKey   string
+		want        string
+	}{
+		{"latest", "hfc/2.zip", "hfc/2.zip", "(current)"},
+		{"older", "hfc/1.zip", "hfc/2.zip", "(not-current)"},
+		{"no uploads", "hfc/1.zip", "", "(not-current)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if got := packageCurrency(tc.stackKey, tc.latestKey); got != tc.want {
+				t.Errorf("unexpected currency; got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// countingWriter counts its calls to Write, and fails them with err if set.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	err    error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestFormatTimeAgo(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{30 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{119 * time.Minute, "1h ago"},
+		{23 * time.Hour, "23h ago"},
+		{50 * time.Hour, "2d ago"},
+	}
+	for _, tc := range testCases {
+		if got := formatTimeAgo(now.Add(-tc.ago), now); got != tc.want {
+			t.Errorf("unexpected result for %v; got %q, want %q", tc.ago, got, tc.want)
+		}
+	}
+}
+
+func TestTabWriter(t *testing.T) {
+	var w countingWriter
+	tw := newTabWriter(&w)
+	tw.WriteRow("(build)", "1234.zip")
+	// A row with a single cell ends an aligned block, which a plain
+	// tabwriter.Writer would write out immediately.
+	tw.WriteRow("HFCStaging")
+	tw.WriteRow("HFCProduction", "1234.zip")
+	if w.writes > 0 {
+		t.Fatalf("wrote %d times before Flush", w.writes)
+	}
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "(build)  1234.zip\nHFCStaging\nHFCProduction  1234.zip\n"
+	if got := w.String(); got != want {
+		t.Errorf("unexpected output; got %q, want %q", got, want)
+	}
+	if w.writes != 1 {
+		t.Errorf("unexpected number of writes; got %d, want 1", w.writes)
+	}
+}
+
+func TestTabWriterError(t *testing.T) {
+	wantErr := errors.New("broken pipe")
+	w := countingWriter{err: wantErr}
+	tw := newTabWriter(&w)
+	tw.WriteRow("(build)", "1234.zip")
+	tw.WriteRow("HFCStaging")
+	if err := tw.Flush(); !errors.Is(err, wantErr) {
+		t.Errorf("unexpected error from Flush; got %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/samber/lo"
+)
+
+// templateBodySizeLimit is the size of the largest template that CloudFormation
+// accepts inline, rather than from S3.
+const templateBodySizeLimit = 51_200
+
+// cfnTemplate is the configured CloudFormation template in the form that the
+// CloudFormation API accepts it. Exactly one of Body or URL is set.
+type cfnTemplate struct {
+	Body *string
+	URL  *string
+}
+
+// uploadTemplate returns the template with the provided body in the form that
+// CloudFormation accepts it. If the template is too large to pass to
+// CloudFormation inline, or the configuration requests it, uploadTemplate
+// uploads the template to the upload bucket and returns its URL instead.
+func uploadTemplate(ctx context.Context, body []byte) (cfnTemplate, error) {
+	if len(body) <= templateBodySizeLimit && !rootConfig.Template.UseS3 {
+		return cfnTemplate{Body: aws.String(string(body))}, nil
+	}
+
+	s3Client, err := newUploadS3Client(ctx, rootConfig.Upload.Bucket)
+	if err != nil {
+		return cfnTemplate{}, err
+	}
+
+	key := templateKey(body)
+	log.Printf("Uploading template to s3://%s/%s", rootConfig.Upload.Bucket, key)
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:               aws.String(rootConfig.Upload.Bucket),
+		Key:                  aws.String(key),
+		Body:                 bytes.NewReader(body),
+		ServerSideEncryption: s3types.ServerSideEncryption(rootConfig.Upload.SSE),
+		SSEKMSKeyId:          lo.EmptyableToPtr(rootConfig.Upload.KMSKeyID),
+		ACL:                  s3types.ObjectCannedACL(rootConfig.Upload.ACL),
+		Tagging:              uploadTagging(),
+	})
+	if err != nil {
+		return cfnTemplate{}, fmt.Errorf("failed to upload template: %w", err)
+	}
+
+	templateURL := url.URL{
+		Scheme: "https",
+		Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", rootConfig.Upload.Bucket, s3Client.Options().Region),
+		Path:   "/" + key,
+	}
+	return cfnTemplate{URL: aws.String(templateURL.String())}, nil
+}
+
+// templateKeyPattern matches the name of an uploaded template after the
+// upload prefix.
+var templateKeyPattern = regexp.MustCompile(`^template-[0-9a-f]{64}(\.[^/]*)?$`)
+
+// templateKey returns the key for uploading the template with the provided
+// body. Keys are based on the template's contents, so deploying the same
+// template to many stacks reuses one object.
+func templateKey(body []byte) string {
+	hash := sha256.Sum256(body)
+	return rootConfig.Upload.Prefix + "template-" + hex.EncodeToString(hash[:]) + filepath.Ext(rootConfig.Template.Path)
+}
+
+// isTemplateKey returns true if key has the form of a template that hfc
+// uploaded with the provided prefix.
+func isTemplateKey(key, prefix string) bool {
+	name, ok := strings.CutPrefix(key, prefix)
+	return ok && templateKeyPattern.MatchString(name)
+}
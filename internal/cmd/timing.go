@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// phaseTiming is the duration of one phase of a command, like a build.
+type phaseTiming struct {
+	Phase    string
+	Duration time.Duration
+}
+
+var (
+	timingsOutput string
+	phaseTimings  []phaseTiming
+)
+
+// addTimingsFlag adds the --timings-output flag to a command whose phases are
+// timed.
+func addTimingsFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&timingsOutput, "timings-output", "", "Write the duration of each phase to this path as JSON")
+}
+
+// timePhase runs a phase of a command, then logs and records its duration,
+// whether or not it succeeded.
+func timePhase(phase string, run func() error) error {
+	start := time.Now()
+	err := run()
+	duration := time.Since(start)
+	phaseTimings = append(phaseTimings, phaseTiming{Phase: phase, Duration: duration})
+	log.Printf("Finished %s in %s", phase, formatDuration(duration))
+	return err
+}
+
+// withPhaseTiming wraps the RunE function of a command that is a single phase,
+// to time it and write the timings for --timings-output.
+func withPhaseTiming(phase string, runE func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		err := timePhase(phase, func() error { return runE(cmd, args) })
+		writePhaseTimings()
+		return err
+	}
+}
+
+// logPhaseTimings logs the total duration of the recorded phases, along with
+// the duration of each.
+func logPhaseTimings(command string) {
+	var (
+		total  time.Duration
+		phases []string
+	)
+	for _, timing := range phaseTimings {
+		total += timing.Duration
+		phases = append(phases, timing.Phase+" "+formatDuration(timing.Duration))
+	}
+	log.Printf("Finished %s in %s (%s)", command, formatDuration(total), strings.Join(phases, ", "))
+}
+
+// writePhaseTimings writes the recorded timings to the --timings-output path,
+// if set. Timings are informational, so a failure to write them is only
+// logged.
+func writePhaseTimings() {
+	if timingsOutput == "" {
+		return
+	}
+
+	type phaseJSON struct {
+		Phase   string  `json:"phase"`
+		Seconds float64 `json:"seconds"`
+	}
+	var output struct {
+		Phases       []phaseJSON `json:"phases"`
+		TotalSeconds float64     `json:"total_seconds"`
+	}
+	for _, timing := range phaseTimings {
+		output.Phases = append(output.Phases, phaseJSON{timing.Phase, timing.Duration.Seconds()})
+		output.TotalSeconds += timing.Duration.Seconds()
+	}
+	body, err := json.MarshalIndent(output, "", "  ")
+	if err == nil {
+		err = os.WriteFile(timingsOutput, append(body, '\n'), 0644)
+	}
+	if err != nil {
+		slog.Warn(fmt.Sprintf("unable to write timings: %v", err))
+	}
+}
+
+// formatDuration formats a duration for logs, rounded to a tenth of a second.
+func formatDuration(d time.Duration) string {
+	return d.Round(100 * time.Millisecond).String()
+}
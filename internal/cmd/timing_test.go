@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWritePhaseTimings(t *testing.T) {
+	previousOutput, previousTimings := timingsOutput, phaseTimings
+	t.Cleanup(func() { timingsOutput, phaseTimings = previousOutput, previousTimings })
+
+	timingsOutput = filepath.Join(t.TempDir(), "timings.json")
+	phaseTimings = []phaseTiming{
+		{Phase: "build", Duration: 1500 * time.Millisecond},
+		{Phase: "upload", Duration: 250 * time.Millisecond},
+	}
+	writePhaseTimings()
+
+	got, err := os.ReadFile(timingsOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+  "phases": [
+    {
+      "phase": "build",
+      "seconds": 1.5
+    },
+    {
+      "phase": "upload",
+      "seconds": 0.25
+    }
+  ],
+  "total_seconds": 1.75
+}
+`
+	if string(got) != want {
+		t.Errorf("unexpected timings; got %s, want %s", got, want)
+	}
+}
@@ -1,93 +1,439 @@
+//lint:file-ignore SA1019 The S3 upload manager is deprecated in favor of feature/s3/transfermanager, which hfc has not yet migrated to.
+
 package cmd
 
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
 	"context"
 	"crypto/sha256"
+	"debug/buildinfo"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/config"
+	"github.com/featherbread/hfc/internal/state"
 )
 
 var uploadCmd = &cobra.Command{
-	Use:    "upload",
-	Short:  "Upload a Lambda deployment package for the latest build",
-	PreRun: initializePreRun,
-	Run:    runUpload,
+	Use:     "upload",
+	Short:   "Upload a Lambda deployment package for the latest build",
+	PreRunE: initializePreRun,
+	RunE:    withPhaseTiming("upload", runUpload),
 }
 
+var (
+	uploadOutput   string
+	uploadPrintKey bool
+)
+
 func init() {
+	uploadCmd.Flags().StringVar(&uploadOutput, "output", "", "Also save the deployment package to this path")
+	uploadCmd.Flags().BoolVar(&uploadPrintKey, "print-key", false, "Print the uploaded S3 key to stdout")
+	uploadCmd.Flags().StringVar(&buildOutputDir, "output-dir", "", "Read the binary from this directory instead of the state directory")
+	addTimingsFlag(uploadCmd)
 	rootCmd.AddCommand(uploadCmd)
 }
 
-func runUpload(cmd *cobra.Command, args []string) {
-	outputPath, err := rootState.BinaryPath(rootConfig.Project.Name)
+func runUpload(cmd *cobra.Command, args []string) error {
+	if err := rootConfig.CheckRequired("project.name", "upload.bucket"); err != nil {
+		return err
+	}
+	if err := checkAWSAccount(cmd.Context(), sts.NewFromConfig(awsConfig), false); err != nil {
+		return err
+	}
+	outputPath, err := binaryPath()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	s3Client, err := newUploadS3Client(cmd.Context(), rootConfig.Upload.Bucket)
+	if err != nil {
+		return err
 	}
 
 	log.Print("Building deployment package")
-	lambdaPackage, err := createLambdaPackage(outputPath)
+	lambdaPackage, err := buildLambdaPackage(outputPath, uploadOutput)
 	if err != nil {
-		log.Fatalf("failed to create deployment package: %v", err)
+		return fmt.Errorf("failed to create deployment package: %w", err)
+	}
+
+	log.Printf("Deployment package is %s", formatBytes(lambdaPackage.Size))
+	warnSize := lo.Ternary(rootConfig.Upload.WarnSizeMiB > 0, rootConfig.Upload.WarnSizeMiB*1024*1024, lambdaZippedSizeLimit)
+	if lambdaPackage.Size > warnSize {
+		slog.Warn(fmt.Sprintf("deployment package exceeds %s (Lambda limits direct uploads to %s)",
+			formatBytes(warnSize), formatBytes(lambdaZippedSizeLimit)))
 	}
 
 	var (
-		s3Client   = s3.NewFromConfig(awsConfig)
 		bucket     = rootConfig.Upload.Bucket
-		key        = rootConfig.Upload.Prefix + strconv.FormatInt(time.Now().Unix(), 10) + ".zip"
-		hashBytes  = sha256.Sum256(lambdaPackage)
-		hashString = base64.StdEncoding.EncodeToString(hashBytes[:])
+		key        = uploadKey(time.Now())
+		hashString = base64.StdEncoding.EncodeToString(lambdaPackage.SHA256)
 	)
 
+	var body io.ReadSeeker = lambdaPackage.Body
+	if !rootQuiet {
+		body = newProgressReader(body, lambdaPackage.Size)
+	}
+
+	uploader := manager.NewUploader(s3Client, func(u *manager.Uploader) {
+		if size := rootConfig.Upload.PartSizeMiB; size > 0 {
+			u.PartSize = size * 1024 * 1024
+		}
+		if n := rootConfig.Upload.Concurrency; n > 0 {
+			u.Concurrency = n
+		}
+	})
+	input := &s3.PutObjectInput{
+		Bucket:               aws.String(bucket),
+		Key:                  aws.String(key),
+		Body:                 body,
+		ContentLength:        aws.Int64(lambdaPackage.Size),
+		ServerSideEncryption: types.ServerSideEncryption(rootConfig.Upload.SSE),
+		SSEKMSKeyId:          lo.EmptyableToPtr(rootConfig.Upload.KMSKeyID),
+		ACL:                  types.ObjectCannedACL(rootConfig.Upload.ACL),
+		Tagging:              uploadTagging(),
+	}
+	// A precomputed checksum of the whole package is only valid for single part
+	// uploads. Multipart uploads instead checksum each part as it's uploaded.
+	if lambdaPackage.Size <= uploader.PartSize {
+		input.ChecksumSHA256 = aws.String(hashString)
+	} else {
+		input.ChecksumAlgorithm = types.ChecksumAlgorithmSha256
+	}
+
 	log.Printf("Uploading deployment package to s3://%s/%s", bucket, key)
-	_, err = s3Client.PutObject(context.Background(), &s3.PutObjectInput{
-		Bucket:         aws.String(bucket),
-		Key:            aws.String(key),
-		Body:           bytes.NewReader(lambdaPackage),
-		ContentLength:  aws.Int64(int64(len(lambdaPackage))),
-		ChecksumSHA256: aws.String(hashString),
+	_, err = uploader.Upload(cmd.Context(), input)
+	closeErr := lambdaPackage.Close()
+	if err != nil {
+		return fmt.Errorf("failed to upload deployment package: %w", err)
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	// The package is in S3 by now, so the key must be recorded even if the upload
+	// was interrupted at the last moment.
+	if err := rootState.WriteLatestPackage(key); err != nil {
+		return err
+	}
+	err = rootState.AppendUploadHistory(state.UploadRecord{
+		Time:     time.Now().UTC(),
+		Key:      key,
+		Size:     lambdaPackage.Size,
+		Revision: binaryRevision(outputPath),
+		SHA256:   hashString,
 	})
 	if err != nil {
-		log.Fatalf("failed to upload deployment package: %v", err)
+		return fmt.Errorf("recording upload history: %w", err)
 	}
+	if uploadPrintKey {
+		fmt.Println(key)
+	}
+	return nil
+}
+
+// uploadTagging returns the configured tags for uploaded objects, encoded as
+// URL query parameters as S3 requires, or nil if no tags are configured.
+// Spaces are percent-encoded, since S3 doesn't reliably decode "+" as a space
+// in tag values.
+func uploadTagging() *string {
+	if len(rootConfig.Upload.Tags) == 0 {
+		return nil
+	}
+	values := make(url.Values, len(rootConfig.Upload.Tags))
+	for key, value := range rootConfig.Upload.Tags {
+		values.Set(key, value)
+	}
+	return aws.String(strings.ReplaceAll(values.Encode(), "+", "%20"))
+}
+
+// binaryRevision returns the version control revision that the Go binary at
+// path was built from, with a "-dirty" suffix if the working tree had local
+// changes, or an empty string if the binary doesn't record it.
+func binaryRevision(path string) string {
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" && modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// newUploadS3Client returns an S3 client for the region of the bucket, normally
+// the configured upload bucket, after verifying that the bucket exists and is
+// accessible with the current credentials.
+//
+// The client works with a bucket in any region, but Lambda only deploys
+// packages from a bucket in the function's region, which deploy checks with
+// checkCodeBucketRegion.
+func newUploadS3Client(ctx context.Context, bucket string) (*s3.Client, error) {
+	s3Client := s3.NewFromConfig(awsConfig)
+
+	region, err := manager.GetBucketRegion(ctx, s3Client, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s not found or not accessible: %w", bucket, err)
+	}
+	if region != awsConfig.Region {
+		log.Printf("Using bucket %s in region %s", bucket, region)
+		s3Client = s3.NewFromConfig(awsConfig, func(o *s3.Options) { o.Region = region })
+	}
+
+	if _, err := s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		return nil, fmt.Errorf("bucket %s not accessible in region %s: %w", bucket, region, err)
+	}
+	return s3Client, nil
+}
+
+// checkCodeBucketRegion returns an error if the bucket holding the Lambda
+// deployment package is in a different region than the stacks, since Lambda
+// requires the package to be in the function's region. If the region of the
+// bucket is unknown, it only logs a warning, and leaves CloudFormation to report
+// any problem.
+func checkCodeBucketRegion(ctx context.Context, bucket string) error {
+	region, err := manager.GetBucketRegion(ctx, s3.NewFromConfig(awsConfig), bucket)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("unable to find the region of bucket %s: %v", bucket, err))
+		return nil
+	}
+	if region != awsConfig.Region {
+		return fmt.Errorf("bucket %s is in region %s, but Lambda requires the deployment package to be in the region of the function (%s)", bucket, region, awsConfig.Region)
+	}
+	return nil
+}
+
+// Size limits for Lambda deployment packages, per the Lambda documentation.
+const (
+	lambdaZippedSizeLimit   = 50 * 1024 * 1024
+	lambdaUnzippedSizeLimit = 250 * 1024 * 1024
+)
 
-	if err := os.WriteFile(rootState.LatestLambdaPackagePath(), append([]byte(key), '\n'), 0644); err != nil {
-		log.Fatal(err)
+// lambdaPackage is a Lambda deployment package that is ready for upload.
+type lambdaPackage struct {
+	Body   io.ReadSeeker
+	Size   int64
+	SHA256 []byte
+
+	file *os.File // nil for packages built in memory
+	keep bool
+}
+
+// Close releases the resources associated with the package, including its
+// temporary file if it has one.
+func (p *lambdaPackage) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	if !p.keep {
+		err = errors.Join(err, os.Remove(p.file.Name()))
 	}
+	return err
 }
 
-func createLambdaPackage(handlerPath string) ([]byte, error) {
-	handlerBinary, err := os.Open(handlerPath)
+// buildLambdaPackage builds a deployment package for the handler binary.
+//
+// Small packages are built in memory. Larger packages are built in a temporary
+// file in the state directory, to avoid holding both the binary and the package
+// in memory at once. If outputPath is non-empty, the package is built there and
+// kept after the upload.
+func buildLambdaPackage(handlerPath, outputPath string) (*lambdaPackage, error) {
+	const inMemoryLimit = 32 * 1024 * 1024
+
+	stat, err := os.Stat(handlerPath)
 	switch {
 	case errors.Is(err, fs.ErrNotExist):
 		return nil, errors.New("must build a binary before uploading")
 	case err != nil:
 		return nil, err
 	}
-	defer handlerBinary.Close()
 
-	var output bytes.Buffer
-	zipWriter := zip.NewWriter(&output)
-	handlerWriter, err := zipWriter.Create("bootstrap")
+	hash := sha256.New()
+
+	if outputPath == "" && stat.Size() < inMemoryLimit {
+		var buf bytes.Buffer
+		if err := createLambdaPackage(io.MultiWriter(&buf, hash), handlerPath, rootConfig.Upload); err != nil {
+			return nil, err
+		}
+		return &lambdaPackage{
+			Body:   bytes.NewReader(buf.Bytes()),
+			Size:   int64(buf.Len()),
+			SHA256: hash.Sum(nil),
+		}, nil
+	}
+
+	var file *os.File
+	if outputPath != "" {
+		file, err = os.Create(outputPath)
+	} else {
+		file, err = os.CreateTemp(rootState.Path(), "lambda-package-*.zip")
+	}
 	if err != nil {
 		return nil, err
 	}
-	if _, err := io.Copy(handlerWriter, handlerBinary); err != nil {
-		return nil, err
+	// The output file is only kept once the package in it is complete.
+	lambdaPackage := &lambdaPackage{Body: file, file: file}
+
+	if err := createLambdaPackage(io.MultiWriter(file, hash), handlerPath, rootConfig.Upload); err != nil {
+		return nil, errors.Join(err, lambdaPackage.Close())
 	}
-	if err := zipWriter.Close(); err != nil {
-		return nil, err
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		return nil, errors.Join(err, lambdaPackage.Close())
+	}
+
+	lambdaPackage.Size = size
+	lambdaPackage.SHA256 = hash.Sum(nil)
+	lambdaPackage.keep = outputPath != ""
+	return lambdaPackage, nil
+}
+
+// lambdaPackageModTime is the modification time of all files in a deployment
+// package, which is the earliest time representable in a .zip file.
+var lambdaPackageModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// createLambdaPackage writes a deployment package containing the handler binary
+// and any extra files to w, using the packaging options in the upload
+// configuration.
+func createLambdaPackage(w io.Writer, handlerPath string, upload config.UploadConfig) error {
+	method := zip.Deflate
+	zipWriter := zip.NewWriter(w)
+	switch upload.Compression {
+	case "store":
+		method = zip.Store
+	case "fastest":
+		registerDeflateLevel(zipWriter, flate.BestSpeed)
+	case "best":
+		registerDeflateLevel(zipWriter, flate.BestCompression)
+	}
+
+	// Lambda's custom runtimes require an executable bootstrap by default.
+	entrypoint := lo.CoalesceOrEmpty(upload.Entrypoint, "bootstrap")
+	if err := addLambdaPackageFile(zipWriter, handlerPath, entrypoint, method, 0755); err != nil {
+		return err
+	}
+	for _, file := range upload.ExtraFiles {
+		stat, err := os.Stat(file.Source)
+		if err != nil {
+			return err
+		}
+		if !stat.Mode().IsRegular() {
+			return fmt.Errorf("extra file %s is not a regular file", file.Source)
+		}
+		if err := addLambdaPackageFile(zipWriter, file.Source, file.ArchivePath, method, stat.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return zipWriter.Close()
+}
+
+// addLambdaPackageFile adds the file at path to a deployment package with the
+// provided name, compression method, and mode. The fixed modification time
+// keeps packages of identical files identical.
+func addLambdaPackageFile(zipWriter *zip.Writer, path, name string, method uint16, mode fs.FileMode) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: lambdaPackageModTime,
+	}
+	header.SetMode(mode)
+
+	fileWriter, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fileWriter, file)
+	return err
+}
+
+func registerDeflateLevel(zipWriter *zip.Writer, level int) {
+	zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+}
+
+// progressReader wraps an io.ReadSeeker of known size, and logs the progress of
+// reads from it at regular intervals.
+type progressReader struct {
+	io.ReadSeeker
+	size     int64
+	read     int64
+	lastTime time.Time
+}
+
+func newProgressReader(r io.ReadSeeker, size int64) *progressReader {
+	return &progressReader{ReadSeeker: r, size: size, lastTime: time.Now()}
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	const interval = 2 * time.Second
+
+	n, err = r.ReadSeeker.Read(p)
+	r.read += int64(n)
+	if now := time.Now(); now.Sub(r.lastTime) >= interval {
+		r.lastTime = now
+		log.Printf("Uploaded %s of %s (%d%%)", formatBytes(r.read), formatBytes(r.size), 100*r.read/max(r.size, 1))
+	}
+	return
+}
+
+func (r *progressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := r.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		r.read = pos
+	}
+	return pos, err
+}
+
+// formatBytes formats a byte count in human-readable binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
-	return output.Bytes(), nil
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
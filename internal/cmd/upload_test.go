@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/featherbread/hfc/internal/config"
+)
+
+func TestCreateLambdaPackage(t *testing.T) {
+	const handlerContents = "#!/bin/sh\necho hfc\n"
+	handlerPath := filepath.Join(t.TempDir(), "handler")
+	if err := os.WriteFile(handlerPath, []byte(handlerContents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := createLambdaPackage(&buf, handlerPath, config.UploadConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zipReader.File) != 1 {
+		t.Fatalf("unexpected number of files in package; got %d, want 1", len(zipReader.File))
+	}
+
+	file := zipReader.File[0]
+	if file.Name != "bootstrap" {
+		t.Errorf("unexpected file name; got %q, want %q", file.Name, "bootstrap")
+	}
+
+	info := file.FileInfo()
+	if mode := info.Mode(); mode != 0755 {
+		t.Errorf("unexpected file mode; got %v, want %v", mode, os.FileMode(0755))
+	}
+	if modTime := info.ModTime(); !modTime.Equal(lambdaPackageModTime) {
+		t.Errorf("unexpected modification time; got %v, want %v", modTime, lambdaPackageModTime)
+	}
+
+	contents, err := file.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer contents.Close()
+	got, err := io.ReadAll(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != handlerContents {
+		t.Errorf("unexpected file contents; got %q, want %q", got, handlerContents)
+	}
+}
+
+func TestCreateLambdaPackageCompression(t *testing.T) {
+	handlerContents := strings.Repeat("hfc ", 1024)
+	handlerPath := filepath.Join(t.TempDir(), "handler")
+	if err := os.WriteFile(handlerPath, []byte(handlerContents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		compression string
+		wantMethod  uint16
+	}{
+		{"", zip.Deflate},
+		{"store", zip.Store},
+		{"fastest", zip.Deflate},
+		{"deflate", zip.Deflate},
+		{"best", zip.Deflate},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.compression, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := createLambdaPackage(&buf, handlerPath, config.UploadConfig{Compression: tc.compression})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := zipReader.File[0]
+			if file.Method != tc.wantMethod {
+				t.Errorf("unexpected compression method; got %d, want %d", file.Method, tc.wantMethod)
+			}
+
+			contents, err := file.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer contents.Close()
+			got, err := io.ReadAll(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != handlerContents {
+				t.Errorf("unexpected file contents after decompression")
+			}
+		})
+	}
+}
+
+func TestCreateLambdaPackageExtraFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"handler":     "#!/bin/sh\necho hfc\n",
+		"NOTICE":      "Copyright hfc authors\n",
+		"config.json": `{"env": "production"}`,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	upload := config.UploadConfig{
+		Entrypoint: "bin/handler",
+		ExtraFiles: []config.PackageFileConfig{
+			{Source: filepath.Join(dir, "NOTICE"), ArchivePath: "NOTICE"},
+			{Source: filepath.Join(dir, "config.json"), ArchivePath: "config/app.json"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := createLambdaPackage(&buf, filepath.Join(dir, "handler"), upload); err != nil {
+		t.Fatal(err)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, file := range zipReader.File {
+		contents, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(contents)
+		contents.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[file.Name] = string(data)
+	}
+
+	want := map[string]string{
+		"bin/handler":     files["handler"],
+		"NOTICE":          files["NOTICE"],
+		"config/app.json": files["config.json"],
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected package contents (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildLambdaPackageOutputCleanup(t *testing.T) {
+	previousConfig := rootConfig
+	t.Cleanup(func() { rootConfig = previousConfig })
+
+	dir := t.TempDir()
+	handlerPath := filepath.Join(dir, "handler")
+	if err := os.WriteFile(handlerPath, []byte("#!/bin/sh\necho hfc\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outputPath := filepath.Join(dir, "package.zip")
+
+	rootConfig = config.Config{Upload: config.UploadConfig{
+		ExtraFiles: []config.PackageFileConfig{{Source: filepath.Join(dir, "missing"), ArchivePath: "missing"}},
+	}}
+	if _, err := buildLambdaPackage(handlerPath, outputPath); err == nil {
+		t.Fatal("buildLambdaPackage succeeded with a missing extra file")
+	}
+	if _, err := os.Stat(outputPath); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("failed package left output behind (stat error %v)", err)
+	}
+
+	rootConfig = config.Config{}
+	lambdaPackage, err := buildLambdaPackage(handlerPath, outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lambdaPackage.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		t.Errorf("complete package was not kept: %v", err)
+	}
+}
+
+func TestUploadTagging(t *testing.T) {
+	previous := rootConfig.Upload.Tags
+	t.Cleanup(func() { rootConfig.Upload.Tags = previous })
+
+	rootConfig.Upload.Tags = nil
+	if got := uploadTagging(); got != nil {
+		t.Errorf("unexpected tagging without tags; got %q, want nil", *got)
+	}
+
+	rootConfig.Upload.Tags = map[string]string{"project": "randomizer", "owner": "team a/b"}
+	want := "owner=team%20a%2Fb&project=randomizer"
+	if got := aws.ToString(uploadTagging()); got != want {
+		t.Errorf("unexpected tagging; got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/state"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [flags] stack",
+	Short: "Check whether a stack deploys the package for the local build",
+	Long: `Check whether a stack deploys the package for the local build
+
+The verify command builds the deployment package for the latest local build,
+without uploading it, and compares its SHA-256 checksum to that of the package
+that the stack currently deploys. It exits with status 1 if they differ.
+
+The checksum of the deployed package comes from S3 when the package was
+uploaded in a single part. For packages uploaded in multiple parts, S3 only
+has a checksum of the parts, so verify uses the checksum recorded in the
+upload history instead.
+`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeStackNames,
+	PreRunE:           initializePreRun,
+	RunE:              runVerify,
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&buildOutputDir, "output-dir", "", "Read the binary from this directory instead of the state directory")
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	if err := rootConfig.CheckRequired("project.name"); err != nil {
+		return err
+	}
+	stackName := args[0]
+	if _, ok := rootConfig.FindStack(stackName); !ok {
+		return fmt.Errorf("stack %s is not configured", stackName)
+	}
+
+	outputPath, err := binaryPath()
+	if err != nil {
+		return err
+	}
+	lambdaPackage, err := buildLambdaPackage(outputPath, "")
+	if err != nil {
+		return fmt.Errorf("failed to create deployment package: %w", err)
+	}
+	lambdaPackage.Close()
+	localSHA256 := base64.StdEncoding.EncodeToString(lambdaPackage.SHA256)
+
+	ctx := cmd.Context()
+	stack, err := describeStack(ctx, cloudformation.NewFromConfig(awsConfig), stackName)
+	if err != nil {
+		return err
+	}
+	artifact, err := getStackArtifact(stack)
+	if err != nil {
+		return err
+	}
+	if artifact.Type != "zip" {
+		return fmt.Errorf("stack %s deploys a container image, which verify can't check", stackName)
+	}
+
+	s3Client, err := newUploadS3Client(ctx, artifact.Bucket)
+	if err != nil {
+		return err
+	}
+	head, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(artifact.Bucket),
+		Key:          aws.String(artifact.Key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return fmt.Errorf("reading checksum of %s: %w", artifact.URI, err)
+	}
+	history, err := rootState.ReadUploadHistory()
+	if err != nil {
+		return err
+	}
+	deployedSHA256, ok := deployedPackageSHA256(head, history, artifact.Key)
+	if !ok {
+		return fmt.Errorf("no SHA-256 checksum is available for %s", artifact.URI)
+	}
+
+	if deployedSHA256 != localSHA256 {
+		log.Printf("Stack %s deploys %s, which does not match the local build", stackName, artifact.URI)
+		log.Printf("Deployed SHA-256: %s", deployedSHA256)
+		log.Printf("Local SHA-256:    %s", localSHA256)
+		return exitCode(1)
+	}
+	log.Printf("Stack %s deploys %s, which matches the local build (SHA-256 %s)", stackName, artifact.URI, localSHA256)
+	return nil
+}
+
+// deployedPackageSHA256 returns the base64-encoded SHA-256 checksum of the
+// package at key, from S3's checksum of the object if it covers the full
+// object, or else from the upload history.
+func deployedPackageSHA256(head *s3.HeadObjectOutput, history []state.UploadRecord, key string) (string, bool) {
+	// S3 reports a checksum of the part checksums for multipart uploads, with
+	// a "-" and the number of parts appended.
+	if checksum := aws.ToString(head.ChecksumSHA256); checksum != "" &&
+		head.ChecksumType != types.ChecksumTypeComposite && !strings.Contains(checksum, "-") {
+		return checksum, true
+	}
+	for _, record := range slices.Backward(history) {
+		if record.Key == key && record.SHA256 != "" {
+			return record.SHA256, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/featherbread/hfc/internal/state"
+)
+
+func TestDeployedPackageSHA256(t *testing.T) {
+	history := []state.UploadRecord{
+		{Key: "hfc/1.zip", SHA256: "old"},
+		{Key: "hfc/2.zip"},
+		{Key: "hfc/1.zip", SHA256: "recorded"},
+	}
+
+	testCases := []struct {
+		description string
+		head        s3.HeadObjectOutput
+		key         string
+		want        string
+		wantOK      bool
+	}{{
+		description: "full object checksum",
+		head:        s3.HeadObjectOutput{ChecksumSHA256: aws.String("fromS3"), ChecksumType: types.ChecksumTypeFullObject},
+		key:         "hfc/1.zip",
+		want:        "fromS3",
+		wantOK:      true,
+	}, {
+		description: "composite checksum",
+		head:        s3.HeadObjectOutput{ChecksumSHA256: aws.String("parts-3"), ChecksumType: types.ChecksumTypeComposite},
+		key:         "hfc/1.zip",
+		want:        "recorded",
+		wantOK:      true,
+	}, {
+		description: "no checksum",
+		key:         "hfc/1.zip",
+		want:        "recorded",
+		wantOK:      true,
+	}, {
+		description: "not recorded",
+		key:         "hfc/2.zip",
+	}, {
+		description: "not in history",
+		key:         "hfc/3.zip",
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, ok := deployedPackageSHA256(&tc.head, history, tc.key)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("unexpected result; got (%q, %v), want (%q, %v)", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
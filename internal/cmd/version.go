@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Display version and build information for hfc",
+	Long: `Display version and build information for hfc
+
+With --verbose, the version command also lists the versions of the modules that
+hfc was built with.
+`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+var versionJSON bool
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print version information to stdout as JSON")
+	rootCmd.AddCommand(versionCmd)
+}
+
+type versionInfo struct {
+	Version      string            `json:"version"`
+	GoVersion    string            `json:"goVersion"`
+	Platform     string            `json:"platform"`
+	Revision     string            `json:"revision,omitempty"`
+	RevisionTime string            `json:"revisionTime,omitempty"`
+	Modified     bool              `json:"modified,omitempty"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	info := versionInfo{
+		Version:   getMainVersion(),
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range buildInfo.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Revision = s.Value
+			case "vcs.time":
+				info.RevisionTime = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+		info.Dependencies = make(map[string]string, len(buildInfo.Deps))
+		for _, dep := range buildInfo.Deps {
+			info.Dependencies[dep.Path] = dep.Version
+		}
+	}
+
+	if versionJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	tw := newTabWriter(os.Stdout)
+	tw.WriteRow("hfc:", info.Version)
+	tw.WriteRow("go:", info.GoVersion)
+	tw.WriteRow("platform:", info.Platform)
+	if info.Revision != "" {
+		tw.WriteRow("revision:", fmt.Sprintf("%s (%s)", info.Revision, info.RevisionTime))
+	}
+	if info.Modified {
+		tw.WriteRow("modified:", "true")
+	}
+	if rootVerbose && len(info.Dependencies) > 0 {
+		tw.WriteRow()
+		tw.WriteRow("dependencies:")
+		for _, path := range slices.Sorted(maps.Keys(info.Dependencies)) {
+			tw.WriteRow("", path, info.Dependencies[path])
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/featherbread/hfc/internal/ignore"
+	"github.com/featherbread/hfc/internal/shelley"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [flags] {stack [parameters] | --build-only}",
+	Short: "Build, upload, and deploy whenever the Go source changes",
+	Long: `Build, upload, and deploy whenever the Go source changes
+
+The watch command builds, uploads, and deploys to the stack once, then again
+whenever a file changes in the directories of the packages in the build. It
+waits for changes to settle for the --debounce duration before starting, so
+that saving several files at once triggers only one deployment. With
+--build-only, it only builds, and needs no stack.
+
+Packages from the module cache are not watched, since they only change along
+with go.mod. Files that .hfcignore excludes never trigger a build. When a
+build produces the same binary as the last deployment, like after a change to
+a test file, watch skips the upload and deployment.
+
+Failures are logged, and watch keeps running until interrupted.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if watchBuildOnly {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
+	ValidArgsFunction: completeParameterKeys,
+	PreRunE:           initializePreRun,
+	RunE:              runWatch,
+}
+
+var (
+	watchBuildOnly bool
+	watchDebounce  time.Duration
+)
+
+func init() {
+	watchCmd.Flags().BoolVar(&watchBuildOnly, "build-only", false, "Only build on changes, without uploading or deploying")
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 500*time.Millisecond, "Wait for changes to stop for this long before building")
+	watchCmd.Flags().BoolVarP(&confirmYes, "yes", "y", false, "Do not ask for confirmation before deploying protected stacks")
+	addParameterFlag(watchCmd)
+	addCapabilityFlag(watchCmd)
+	rootCmd.AddCommand(watchCmd)
+}
+
+// goListWatchTemplate is a go list template that prints the paths of the files
+// that affect the build of each package outside of the standard library and
+// module cache, one per line.
+const goListWatchTemplate = `{{if and (not .Standard) (or (not .Module) .Module.Main .Module.Replace)}}` +
+	goListPackageFilesTemplate +
+	`{{end}}`
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	required := []string{"project.name", "build.path"}
+	if !watchBuildOnly {
+		required = append(required, "upload.bucket", "template.path")
+	}
+	if err := rootConfig.CheckRequired(required...); err != nil {
+		return err
+	}
+	if !watchBuildOnly {
+		if _, err := findStacks(args[0]); err != nil {
+			return err
+		}
+		if _, err := cliDeployParameters(args[1:]); err != nil {
+			return err
+		}
+	}
+
+	projectDir := projectDir()
+	matcher, err := ignore.Load(filepath.Join(projectDir, ignore.Filename))
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	ctx := cmd.Context()
+	var deployedHash string
+	for {
+		// The packages in the build can change with every build, so the set of
+		// watched directories is updated each time.
+		if err := watchBuildDirs(watcher); err != nil {
+			slog.Warn(fmt.Sprintf("unable to update watched directories: %v", err))
+		}
+
+		if err := runWatchCycle(cmd, args, &deployedHash); err != nil {
+			slog.Error(err.Error())
+		}
+		log.Print("Waiting for changes")
+
+		if err := waitForChanges(ctx, watcher, projectDir, matcher); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// runWatchCycle builds the binary, then uploads and deploys it unless it's the
+// same as the binary with the hash at deployedHash, which is updated after a
+// successful deployment.
+func runWatchCycle(cmd *cobra.Command, args []string, deployedHash *string) error {
+	if err := runBuild(cmd, args); err != nil {
+		return fmt.Errorf("build failed: %w", err)
+	}
+	if watchBuildOnly {
+		return nil
+	}
+
+	outputPath, err := binaryPath()
+	if err != nil {
+		return err
+	}
+	hash, err := hashFile(outputPath)
+	if err != nil {
+		return err
+	}
+	if hash == *deployedHash {
+		log.Print("Binary is unchanged since the last deployment, skipping deploy")
+		return nil
+	}
+
+	if err := runUpload(cmd, args); err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	if err := runDeploy(cmd, args); err != nil {
+		return fmt.Errorf("deploy failed: %w", err)
+	}
+	*deployedHash = hash
+	return nil
+}
+
+// watchBuildDirs adds the directories of the files in the build to the
+// watcher.
+func watchBuildDirs(watcher *fsnotify.Watcher) error {
+	var stdout bytes.Buffer
+	goContext := &shelley.Context{
+		Stdout:      &stdout,
+		Stderr:      os.Stderr,
+		DebugLogger: shelley.DefaultContext.DebugLogger,
+	}
+	err := withGoBuildEnv(goContext.Command(
+		goBinary(), "list", "-deps",
+		"-tags", goBuildTags(),
+		"-f", goListWatchTemplate,
+		rootConfig.Build.Path,
+	)).Run()
+	if err != nil {
+		return err
+	}
+
+	dirs := lo.Uniq(lo.Map(strings.Fields(stdout.String()), func(file string, _ int) string {
+		return filepath.Dir(file)
+	}))
+	for _, dir := range dirs {
+		if slices.Contains(watcher.WatchList(), dir) {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watching %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// waitForChanges waits for a relevant change to a watched file, then for
+// changes to stop for the debounce duration.
+func waitForChanges(ctx context.Context, watcher *fsnotify.Watcher, projectDir string, matcher ignore.Matcher) error {
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("file watcher stopped")
+			}
+			if isRelevantChange(event, projectDir, matcher) {
+				log.Printf("Changed: %s", event.Name)
+				debounce = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("file watcher stopped")
+			}
+			slog.Warn(fmt.Sprintf("file watcher: %v", err))
+		case <-debounce:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// isRelevantChange returns true if the event might change the build. Changes
+// to permissions alone, to hidden files like editor swap files, to backup
+// files ending in "~", and to files that .hfcignore excludes are irrelevant.
+func isRelevantChange(event fsnotify.Event, projectDir string, matcher ignore.Matcher) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(event.Name)
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return false
+	}
+	rel, err := filepath.Rel(projectDir, event.Name)
+	if err == nil && filepath.IsLocal(rel) && matcher.Match(filepath.ToSlash(rel), false) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/featherbread/hfc/internal/ignore"
+)
+
+func TestIsRelevantChange(t *testing.T) {
+	projectDir := filepath.FromSlash("/src/app")
+	matcher, err := ignore.Parse(strings.NewReader("static/generated/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name string
+		op   fsnotify.Op
+		want bool
+	}{
+		{"/src/app/main.go", fsnotify.Write, true},
+		{"/src/app/main.go", fsnotify.Chmod, false},
+		{"/src/app/main.go", fsnotify.Write | fsnotify.Chmod, true},
+		{"/src/app/handler.go", fsnotify.Remove, true},
+		{"/src/app/.main.go.swp", fsnotify.Write, false},
+		{"/src/app/main.go~", fsnotify.Create, false},
+		{"/src/app/static/generated/app.js", fsnotify.Write, false},
+		{"/src/app/static/app.js", fsnotify.Write, true},
+		{"/src/lib/static/generated/app.js", fsnotify.Write, true},
+	}
+	for _, tc := range testCases {
+		event := fsnotify.Event{Name: filepath.FromSlash(tc.name), Op: tc.op}
+		if got := isRelevantChange(event, projectDir, matcher); got != tc.want {
+			t.Errorf("isRelevantChange(%v) = %v, want %v", event, got, tc.want)
+		}
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 
 	"dario.cat/mergo"
 	"github.com/BurntSushi/toml"
+	"github.com/samber/lo"
 )
 
 const (
@@ -17,29 +21,88 @@ const (
 	LocalFilename = "hfc.local.toml"
 )
 
+// LoadOptions controls how Load finds and merges configuration files.
+type LoadOptions struct {
+	// Path is the path to the base configuration. If empty, Load uses FindPath
+	// to find the base configuration.
+	Path string
+	// NoLocal skips the local configuration, even if it exists.
+	NoLocal bool
+	// Env selects an environment configuration, whose file name is given by
+	// EnvFilename, to merge over the base configuration. The environment
+	// configuration must exist if Env is set.
+	Env string
+}
+
+// EnvFilename returns the base name of the configuration file for the named
+// environment.
+func EnvFilename(env string) string {
+	return "hfc." + env + ".toml"
+}
+
 // Load automatically loads the full configuration by finding, loading, and
-// merging the base and local configurations.
-func Load() (Config, error) {
-	baseConfigPath, err := FindPath()
-	if err != nil {
-		return Config{}, err
+// merging the base, environment, and local configurations. The environment and
+// local configurations are always found in the directory of the base
+// configuration.
+//
+// Files listed in a configuration's include directive are merged under that
+// configuration, in the order listed. That is, in increasing order of
+// precedence, Load merges the files included by the base configuration, the
+// base configuration, the files included by the environment configuration, the
+// environment configuration, the files included by the local configuration, and
+// the local configuration. Local configuration is last so that developer
+// overrides apply to every environment.
+func Load(opts LoadOptions) (Config, error) {
+	baseConfigPath := opts.Path
+	if baseConfigPath == "" {
+		var err error
+		baseConfigPath, err = FindPath()
+		if err != nil {
+			return Config{}, err
+		}
 	}
 
-	baseConfig, err := LoadFile(baseConfigPath)
+	baseConfig, err := loadFileWithIncludes(baseConfigPath, nil)
 	if err != nil {
 		return Config{}, err
 	}
 
+	var envConfig Config
+	if opts.Env != "" {
+		if !isEnvName(opts.Env) {
+			return Config{}, fmt.Errorf("invalid environment name %q", opts.Env)
+		}
+		envConfigPath := filepath.Join(filepath.Dir(baseConfigPath), EnvFilename(opts.Env))
+		envConfig, err = loadFileWithIncludes(envConfigPath, nil)
+		if err != nil {
+			return Config{}, fmt.Errorf("loading configuration for environment %s: %w", opts.Env, err)
+		}
+	}
+
 	var localConfig Config
 	localConfigPath := filepath.Join(filepath.Dir(baseConfigPath), LocalFilename)
-	if _, err := os.Stat(localConfigPath); err == nil {
-		localConfig, err = LoadFile(localConfigPath)
+	if _, err := os.Stat(localConfigPath); err == nil && !opts.NoLocal {
+		localConfig, err = loadFileWithIncludes(localConfigPath, nil)
 		if err != nil {
 			return Config{}, err
 		}
 	}
 
-	return Merge(baseConfig, localConfig), nil
+	config, err := Merge(baseConfig, envConfig, localConfig)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := config.Check(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// isEnvName returns true if env can name an environment configuration file.
+// The name "local" is reserved for the local configuration.
+func isEnvName(env string) bool {
+	return env != "local" && env != "." && env != ".." &&
+		!strings.ContainsAny(env, `/\`)
 }
 
 // FindPath returns the rooted path to the configuration file in the current
@@ -75,19 +138,192 @@ func LoadFile(path string) (Config, error) {
 	defer file.Close()
 
 	var config Config
-	_, err = toml.NewDecoder(file).Decode(&config)
-	return config, err
+	if _, err := toml.NewDecoder(file).Decode(&config); err != nil {
+		return Config{}, err
+	}
+
+	for _, replace := range config.Replace {
+		if _, ok := findListField(&config, replace); !ok {
+			return Config{}, fmt.Errorf("%s: cannot replace %q, which is not a list field", path, replace)
+		}
+	}
+	return config, nil
+}
+
+// loadFileWithIncludes loads configuration from a TOML file, and merges it
+// over the configurations of the files that it includes. The including paths
+// are the absolute paths of the files whose includes led to this one, and are
+// used to detect include cycles.
+func loadFileWithIncludes(path string, including []string) (Config, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if slices.Contains(including, absPath) {
+		cycle := append(slices.Clone(including), absPath)
+		return Config{}, fmt.Errorf("configuration include cycle: %s", strings.Join(cycle, " -> "))
+	}
+	including = append(slices.Clip(including), absPath)
+
+	config, err := LoadFile(absPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	configs := make([]Config, 0, len(config.Include)+1)
+	for _, include := range config.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(absPath), include)
+		}
+		included, err := loadFileWithIncludes(include, including)
+		if err != nil {
+			return Config{}, err
+		}
+		configs = append(configs, included)
+	}
+
+	config.Include = nil
+	configs = append(configs, config)
+	return Merge(configs...)
 }
 
 // Merge deeply merges the provided configs, overriding the values in earlier
 // configs with those in later configs.
-func Merge(configs ...Config) Config {
+//
+// Lists in later configs extend the lists in earlier configs, unless a later
+// config names the list in its replace directive. Duplicate values are removed
+// from lists that represent sets, like build tags and template capabilities.
+func Merge(configs ...Config) (Config, error) {
 	var result Config
 	for _, config := range configs {
+		for _, path := range config.Replace {
+			if field, ok := findListField(&result, path); ok {
+				field.SetZero()
+			}
+		}
 		err := mergo.Merge(&result, config, mergo.WithOverride, mergo.WithAppendSlice)
 		if err != nil {
-			panic(err)
+			return Config{}, fmt.Errorf("failed to merge configuration: %w", err)
+		}
+	}
+
+	result.Replace = nil
+	result.Build.Tags = uniq(result.Build.Tags)
+	result.Template.Capabilities = uniq(result.Template.Capabilities)
+	result.Template.NotificationARNs = uniq(result.Template.NotificationARNs)
+	return result, nil
+}
+
+// findListField returns the slice or map field of the config at the dotted
+// path of TOML keys.
+func findListField(config *Config, path string) (reflect.Value, bool) {
+	v := reflect.ValueOf(config).Elem()
+	for name := range strings.SplitSeq(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		field, ok := lo.Find(reflect.VisibleFields(v.Type()), func(f reflect.StructField) bool {
+			return f.Tag.Get("toml") == name
+		})
+		if !ok {
+			return reflect.Value{}, false
+		}
+		v = v.FieldByIndex(field.Index)
+	}
+	return v, v.Kind() == reflect.Slice || v.Kind() == reflect.Map
+}
+
+// ResolveStrings replaces every string value in the configuration, including
+// the values in lists and maps, with the result of calling resolve with the
+// dotted path of TOML keys to the value and the value itself.
+func (c *Config) ResolveStrings(resolve func(path, value string) (string, error)) error {
+	return resolveStrings(reflect.ValueOf(c).Elem(), "", resolve)
+}
+
+func resolveStrings(v reflect.Value, path string, resolve func(path, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		resolved, err := resolve(path, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(resolved)
+
+	case reflect.Struct:
+		for _, field := range reflect.VisibleFields(v.Type()) {
+			name := field.Tag.Get("toml")
+			if name == "" {
+				continue
+			}
+			if err := resolveStrings(v.FieldByIndex(field.Index), joinPath(path, name), resolve); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		for i := range v.Len() {
+			if err := resolveStrings(v.Index(i), path, resolve); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		// Map values aren't addressable, so each one is resolved in a copy that
+		// replaces the original.
+		for _, key := range v.MapKeys() {
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(v.MapIndex(key))
+			if err := resolveStrings(value, joinPath(path, key.String()), resolve); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	}
+	return nil
+}
+
+// ResolvePaths makes the relative paths to local files in the configuration
+// relative to dir, normally the directory of the base configuration, so that
+// they don't depend on the directory that hfc runs in. Inline stack policies
+// are left alone.
+func (c *Config) ResolvePaths(dir string) {
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
 		}
 	}
-	return result
+	resolvePolicy := func(policy *string) {
+		if !IsInlinePolicy(*policy) {
+			resolve(policy)
+		}
+	}
+
+	resolve(&c.Template.Path)
+	resolvePolicy(&c.Template.StackPolicy)
+	for i := range c.Upload.ExtraFiles {
+		resolve(&c.Upload.ExtraFiles[i].Source)
+	}
+	for i := range c.Stacks {
+		resolvePolicy(&c.Stacks[i].StackPolicy)
+	}
+}
+
+// IsInlinePolicy returns true if a stack policy setting holds inline JSON
+// rather than the path to a JSON file.
+func IsInlinePolicy(policy string) bool {
+	return strings.HasPrefix(strings.TrimSpace(policy), "{")
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func uniq(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return lo.Uniq(values)
 }
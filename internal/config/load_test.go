@@ -1,6 +1,9 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -36,7 +39,7 @@ func TestLoad(t *testing.T) {
 
 	t.Chdir("testdata")
 
-	got, err := Load()
+	got, err := Load(LoadOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,3 +48,351 @@ func TestLoad(t *testing.T) {
 		t.Errorf("unexpected result (-want +got):\n%s", diff)
 	}
 }
+
+func TestLoadPath(t *testing.T) {
+	want, err := Load(LoadOptions{Path: filepath.Join("testdata", Filename)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir("testdata")
+
+	got, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadNoLocal(t *testing.T) {
+	want := Config{
+		Project: ProjectConfig{
+			Name: "hfc",
+		},
+		Build: BuildConfig{
+			Path: "./cmd/hfc",
+			Tags: []string{"grpcnotrace"},
+		},
+		Upload: UploadConfig{
+			Bucket: "hfc",
+		},
+		Template: TemplateConfig{
+			Path:         "CloudFormation.yaml",
+			Capabilities: []string{"CAPABILITY_IAM"},
+		},
+	}
+
+	t.Chdir("testdata")
+
+	got, err := Load(LoadOptions{NoLocal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Chdir("testdata")
+
+	want, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The environment configuration overrides the base configuration, but not
+	// the local configuration.
+	want.Upload.Bucket = "hfc-staging"
+
+	got, err := Load(LoadOptions{Env: "staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+
+	got, err = Load(LoadOptions{Env: "staging", NoLocal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AWS.Region != "us-east-1" {
+		t.Errorf("unexpected region without local configuration; got %q, want %q", got.AWS.Region, "us-east-1")
+	}
+
+	for _, env := range []string{"production", "local", "../staging"} {
+		if _, err := Load(LoadOptions{Env: env}); err == nil {
+			t.Errorf("Load succeeded with environment %q", env)
+		}
+	}
+}
+
+func TestLoadInclude(t *testing.T) {
+	want := Config{
+		Project: ProjectConfig{
+			Name: "hfc",
+		},
+		AWS: AWSConfig{
+			Region: "us-west-2",
+		},
+		Template: TemplateConfig{
+			Path:         "CloudFormation.yaml",
+			Capabilities: []string{"CAPABILITY_IAM"},
+		},
+	}
+
+	t.Chdir(filepath.Join("testdata", "include", "project"))
+
+	got, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadIncludeCycle(t *testing.T) {
+	t.Chdir(filepath.Join("testdata", "cycle"))
+
+	_, err := Load(LoadOptions{})
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("unexpected error; got %v, want include cycle error", err)
+	}
+}
+
+func TestLoadProtected(t *testing.T) {
+	want := Config{
+		Project: ProjectConfig{
+			Name: "hfc",
+		},
+		Stacks: []StackConfig{
+			{Name: "HFCStaging"},
+			{Name: "HFCProduction", Protected: true},
+		},
+	}
+
+	t.Chdir(filepath.Join("testdata", "protected"))
+
+	got, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Config{
+		Build: BuildConfig{Tags: []string{"grpcnotrace"}},
+		Template: TemplateConfig{
+			Path:         "CloudFormation.yaml",
+			Capabilities: []string{"CAPABILITY_IAM"},
+		},
+	}
+
+	testCases := []struct {
+		description string
+		override    Config
+		want        Config
+	}{{
+		description: "append",
+		override: Config{
+			Build: BuildConfig{Tags: []string{"debug"}},
+		},
+		want: Config{
+			Build:    BuildConfig{Tags: []string{"grpcnotrace", "debug"}},
+			Template: base.Template,
+		},
+	}, {
+		description: "deduplicate",
+		override: Config{
+			Template: TemplateConfig{Capabilities: []string{"CAPABILITY_IAM", "CAPABILITY_AUTO_EXPAND"}},
+		},
+		want: Config{
+			Build: base.Build,
+			Template: TemplateConfig{
+				Path:         "CloudFormation.yaml",
+				Capabilities: []string{"CAPABILITY_IAM", "CAPABILITY_AUTO_EXPAND"},
+			},
+		},
+	}, {
+		description: "replace",
+		override: Config{
+			Replace:  []string{"build.tags", "template.capabilities"},
+			Build:    BuildConfig{Tags: []string{"debug"}},
+			Template: TemplateConfig{Capabilities: nil},
+		},
+		want: Config{
+			Build:    BuildConfig{Tags: []string{"debug"}},
+			Template: TemplateConfig{Path: "CloudFormation.yaml"},
+		},
+	}, {
+		description: "build environment",
+		override: Config{
+			Build: BuildConfig{Env: map[string]string{"GOFLAGS": "-mod=mod"}},
+		},
+		want: Config{
+			Build:    BuildConfig{Tags: []string{"grpcnotrace"}, Env: map[string]string{"GOFLAGS": "-mod=mod"}},
+			Template: base.Template,
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := Merge(base, tc.override)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadFileInvalidReplace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Filename)
+	if err := os.WriteFile(path, []byte(`replace = ["project.name"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadFile(path); err == nil {
+		t.Error("LoadFile succeeded with a replace directive for a non-list field")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		description string
+		config      Config
+		wantErr     bool
+	}{
+		{"empty", Config{}, false},
+		{"expected account", Config{AWS: AWSConfig{ExpectedAccountID: "123456789012"}}, false},
+		{"invalid expected account", Config{AWS: AWSConfig{ExpectedAccountID: "1234-5678-9012"}}, true},
+		{"AES256", Config{Upload: UploadConfig{SSE: "AES256"}}, false},
+		{"KMS", Config{Upload: UploadConfig{SSE: "aws:kms"}}, false},
+		{"KMS with key", Config{Upload: UploadConfig{SSE: "aws:kms", KMSKeyID: "alias/hfc"}}, false},
+		{"unknown SSE", Config{Upload: UploadConfig{SSE: "rot13"}}, true},
+		{"key without SSE", Config{Upload: UploadConfig{KMSKeyID: "alias/hfc"}}, true},
+		{"key with AES256", Config{Upload: UploadConfig{SSE: "AES256", KMSKeyID: "alias/hfc"}}, true},
+		{"part size", Config{Upload: UploadConfig{PartSizeMiB: 16}}, false},
+		{"small part size", Config{Upload: UploadConfig{PartSizeMiB: 1}}, true},
+		{"negative concurrency", Config{Upload: UploadConfig{Concurrency: -1}}, true},
+		{"compression", Config{Upload: UploadConfig{Compression: "best"}}, false},
+		{"key format", Config{Upload: UploadConfig{KeyFormat: "20060102-150405"}}, false},
+		{"key format without time", Config{Upload: UploadConfig{KeyFormat: "2006-01-02"}}, true},
+		{"unknown compression", Config{Upload: UploadConfig{Compression: "zstd"}}, true},
+		{"tags", Config{Upload: UploadConfig{Tags: map[string]string{"project": "randomizer", "retention": ""}}}, false},
+		{"empty tag key", Config{Upload: UploadConfig{Tags: map[string]string{"": "x"}}}, true},
+		{"reserved tag key", Config{Upload: UploadConfig{Tags: map[string]string{"aws:project": "x"}}}, true},
+		{"invalid tag value", Config{Upload: UploadConfig{Tags: map[string]string{"project": "a&b"}}}, true},
+		{"long tag value", Config{Upload: UploadConfig{Tags: map[string]string{"project": strings.Repeat("x", 257)}}}, true},
+		{
+			"too many tags",
+			Config{Upload: UploadConfig{Tags: map[string]string{
+				"a": "", "b": "", "c": "", "d": "", "e": "", "f": "", "g": "", "h": "", "i": "", "j": "", "k": "",
+			}}},
+			true,
+		},
+		{"layers", Config{Template: TemplateConfig{Layers: []string{"arn:aws:lambda:us-west-2:123456789012:layer:shared:3"}}}, false},
+		{"invalid layer", Config{Stacks: []StackConfig{{Layers: []string{"shared:3"}}}}, true},
+		{
+			"publish alias",
+			Config{Stacks: []StackConfig{{PublishAlias: PublishAliasConfig{FunctionOutput: "Function", Alias: "live"}}}},
+			false,
+		},
+		{
+			"publish alias without output",
+			Config{Stacks: []StackConfig{{PublishAlias: PublishAliasConfig{Alias: "live"}}}},
+			true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := tc.config.Check()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveStrings(t *testing.T) {
+	config := Config{
+		AWS:    AWSConfig{Region: "us-west-2"},
+		Build:  BuildConfig{Tags: []string{"grpcnotrace"}},
+		Upload: UploadConfig{Bucket: "ssm:/hfc/bucket"},
+		Stacks: []StackConfig{{
+			Name:       "HFCStaging",
+			Parameters: map[string]string{"DomainName": "ssm:/hfc/domain"},
+		}},
+	}
+
+	var paths []string
+	err := config.ResolveStrings(func(path, value string) (string, error) {
+		if value != "" {
+			paths = append(paths, path)
+		}
+		return strings.ToUpper(value), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Config{
+		AWS:    AWSConfig{Region: "US-WEST-2"},
+		Build:  BuildConfig{Tags: []string{"GRPCNOTRACE"}},
+		Upload: UploadConfig{Bucket: "SSM:/HFC/BUCKET"},
+		Stacks: []StackConfig{{
+			Name:       "HFCSTAGING",
+			Parameters: map[string]string{"DomainName": "SSM:/HFC/DOMAIN"},
+		}},
+	}
+	if diff := cmp.Diff(want, config); diff != "" {
+		t.Errorf("unexpected config (-want +got):\n%s", diff)
+	}
+
+	wantPaths := []string{"aws.region", "build.tags", "upload.bucket", "stacks.name", "stacks.parameters.DomainName"}
+	if diff := cmp.Diff(wantPaths, paths); diff != "" {
+		t.Errorf("unexpected paths (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	config := Config{
+		Template: TemplateConfig{Path: "template.yaml", StackPolicy: "policy.json"},
+		Upload: UploadConfig{ExtraFiles: []PackageFileConfig{
+			{Source: "config/app.json", ArchivePath: "app.json"},
+			{Source: "/etc/ssl/cert.pem", ArchivePath: "cert.pem"},
+		}},
+		Stacks: []StackConfig{
+			{Name: "HFCStaging", StackPolicy: `{"Statement": []}`},
+			{Name: "HFCProduction", StackPolicy: "../policies/production.json"},
+		},
+	}
+	config.ResolvePaths("/project")
+
+	want := Config{
+		Template: TemplateConfig{Path: "/project/template.yaml", StackPolicy: "/project/policy.json"},
+		Upload: UploadConfig{ExtraFiles: []PackageFileConfig{
+			{Source: "/project/config/app.json", ArchivePath: "app.json"},
+			{Source: "/etc/ssl/cert.pem", ArchivePath: "cert.pem"},
+		}},
+		Stacks: []StackConfig{
+			{Name: "HFCStaging", StackPolicy: `{"Statement": []}`},
+			{Name: "HFCProduction", StackPolicy: "/policies/production.json"},
+		},
+	}
+	if diff := cmp.Diff(want, config); diff != "" {
+		t.Errorf("unexpected config (-want +got):\n%s", diff)
+	}
+}
@@ -1,14 +1,35 @@
 package config
 
-import "github.com/samber/lo"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+)
 
 // Config represents a full configuration.
 type Config struct {
+	// Include lists paths to other configuration files, relative to the file
+	// that includes them, whose values are merged under this configuration.
+	Include []string `toml:"include"`
+	// Replace lists the dotted paths of list fields, like "build.tags", whose
+	// values in this configuration replace rather than extend the values of
+	// the configurations it is merged over.
+	Replace []string `toml:"replace"`
+
 	Project  ProjectConfig  `toml:"project"`
 	AWS      AWSConfig      `toml:"aws"`
 	Build    BuildConfig    `toml:"build"`
 	Upload   UploadConfig   `toml:"upload"`
 	Template TemplateConfig `toml:"template"`
+	Hooks    HooksConfig    `toml:"hooks"`
 	Stacks   []StackConfig  `toml:"stacks"`
 }
 
@@ -18,6 +39,54 @@ func (c *Config) FindStack(name string) (stack StackConfig, ok bool) {
 	return lo.Find(c.Stacks, func(s StackConfig) bool { return s.Name == name })
 }
 
+// Check returns an error if the configuration contains invalid values.
+func (c *Config) Check() error {
+	if err := c.AWS.check(); err != nil {
+		return err
+	}
+	if err := c.Upload.check(); err != nil {
+		return err
+	}
+	if err := c.Template.check(); err != nil {
+		return err
+	}
+	for _, stack := range c.Stacks {
+		if err := stack.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckRequired returns an error if any of the settings at the dotted paths,
+// like "upload.bucket", are empty. Commands check only the settings they need,
+// so that commands that inspect stacks work with a partial configuration.
+func (c *Config) CheckRequired(paths ...string) error {
+	var missing []string
+	for _, path := range paths {
+		var value string
+		switch path {
+		case "project.name":
+			value = c.Project.Name
+		case "build.path":
+			value = c.Build.Path
+		case "upload.bucket":
+			value = c.Upload.Bucket
+		case "template.path":
+			value = c.Template.Path
+		default:
+			panic(fmt.Sprintf("config: unknown required setting %q", path))
+		}
+		if value == "" {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 // ProjectConfig represents the configuration for this project, which is
 // expected to be common across all possible deployments.
 type ProjectConfig struct {
@@ -27,33 +96,340 @@ type ProjectConfig struct {
 // AWSConfig represents the configuration for all AWS operations in this
 // project.
 type AWSConfig struct {
-	Region string `toml:"region"`
+	Region  string `toml:"region"`
+	Profile string `toml:"profile"`
+
+	// ExpectedAccountID is the ID of the only AWS account that commands which
+	// change AWS resources may operate on, to guard against using the wrong
+	// credentials.
+	ExpectedAccountID string `toml:"expected_account_id"`
+}
+
+func (a *AWSConfig) check() error {
+	if id := a.ExpectedAccountID; id != "" && (len(id) != 12 || strings.Trim(id, "0123456789") != "") {
+		return fmt.Errorf("aws.expected_account_id must be a 12 digit account ID, got %q", id)
+	}
+	return nil
 }
 
 // BuildConfig represents the configuration for building a deployable Go binary.
 type BuildConfig struct {
-	Path string   `toml:"path"`
-	Tags []string `toml:"tags"`
+	Path string            `toml:"path"`
+	Tags []string          `toml:"tags"`
+	Env  map[string]string `toml:"env"`
+
+	// GoBinary is the name or path of the go command that builds the binary.
+	// The default is "go", found in the PATH.
+	GoBinary string `toml:"go_binary"`
 }
 
 // UploadConfig represents the configuration for uploading a Go binary in a
 // Lambda .zip archive to an Amazon S3 bucket.
 type UploadConfig struct {
-	Bucket string `toml:"bucket"`
-	Prefix string `toml:"prefix"`
+	Bucket   string `toml:"bucket"`
+	Prefix   string `toml:"prefix"`
+	SSE      string `toml:"sse"`
+	KMSKeyID string `toml:"kms_key_id"`
+	ACL      string `toml:"acl"`
+
+	// PartSizeMiB and Concurrency tune multipart uploads of large packages. Zero
+	// values select the defaults of the AWS SDK's upload manager.
+	PartSizeMiB int64 `toml:"part_size_mib"`
+	Concurrency int   `toml:"concurrency"`
+
+	// Compression is the compression applied to the deployment package: one of
+	// "store", "fastest", "deflate", or "best". The default is "deflate".
+	Compression string `toml:"compression"`
+
+	// WarnSizeMiB is the package size above which uploads log a warning. Zero
+	// selects a default near the limit for direct uploads to Lambda.
+	WarnSizeMiB int64 `toml:"warn_size_mib"`
+
+	// Entrypoint is the path of the binary in the deployment package. The
+	// default is "bootstrap", as Lambda's custom runtimes require.
+	Entrypoint string `toml:"entrypoint"`
+	// ExtraFiles lists additional files to include in the deployment package.
+	ExtraFiles []PackageFileConfig `toml:"extra_files"`
+
+	// KeyFormat is a Go time layout, like "20060102-150405", for the upload
+	// time in the keys of deployment packages. Times are formatted in UTC. The
+	// default is a Unix timestamp.
+	KeyFormat string `toml:"key_format"`
+
+	// Tags are applied to every uploaded object, for bucket lifecycle rules
+	// that expire old uploads by tag.
+	Tags map[string]string `toml:"tags"`
+}
+
+// PackageFileConfig represents a local file to include in a Lambda deployment
+// package.
+type PackageFileConfig struct {
+	// Source is the path to the local file.
+	Source string `toml:"source"`
+	// ArchivePath is the path of the file within the package.
+	ArchivePath string `toml:"archive_path"`
+}
+
+func (u *UploadConfig) check() error {
+	if u.KeyFormat != "" {
+		// A layout that round trips a time with seconds includes the full date
+		// and time, so that keys from different uploads are distinct and
+		// parseable.
+		want := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+		got, err := time.Parse(u.KeyFormat, want.Format(u.KeyFormat))
+		if err != nil || !got.Equal(want) {
+			return fmt.Errorf("upload.key_format %q must include the full date and time to the second", u.KeyFormat)
+		}
+	}
+	switch u.SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return fmt.Errorf(`upload.sse must be "AES256" or "aws:kms", got %q`, u.SSE)
+	}
+	if u.KMSKeyID != "" && u.SSE != "aws:kms" {
+		return errors.New(`upload.kms_key_id requires upload.sse = "aws:kms"`)
+	}
+	switch u.Compression {
+	case "", "store", "fastest", "deflate", "best":
+	default:
+		return fmt.Errorf(`upload.compression must be "store", "fastest", "deflate", or "best", got %q`, u.Compression)
+	}
+	if u.PartSizeMiB != 0 && u.PartSizeMiB < 5 {
+		return fmt.Errorf("upload.part_size_mib must be at least 5, got %d", u.PartSizeMiB)
+	}
+	if u.WarnSizeMiB < 0 {
+		return fmt.Errorf("upload.warn_size_mib must not be negative, got %d", u.WarnSizeMiB)
+	}
+	if u.Concurrency < 0 {
+		return fmt.Errorf("upload.concurrency must not be negative, got %d", u.Concurrency)
+	}
+	if err := u.checkTags(); err != nil {
+		return err
+	}
+	return u.checkPackagePaths()
+}
+
+// maxObjectTags is the most tags that S3 allows on an object.
+const maxObjectTags = 10
+
+// objectTagPattern matches the characters that S3 allows in the keys and
+// values of object tags.
+var objectTagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
+// checkTags ensures that the upload tags meet S3's limits on object tags.
+func (u *UploadConfig) checkTags() error {
+	if len(u.Tags) > maxObjectTags {
+		return fmt.Errorf("upload.tags has %d tags, but S3 allows at most %d", len(u.Tags), maxObjectTags)
+	}
+	for key, value := range u.Tags {
+		switch {
+		case key == "" || utf8.RuneCountInString(key) > 128:
+			return fmt.Errorf("upload.tags key %q must be 1 to 128 characters", key)
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return fmt.Errorf(`upload.tags key %q must not start with "aws:"`, key)
+		case !objectTagPattern.MatchString(key):
+			return fmt.Errorf("upload.tags key %q contains characters that S3 doesn't allow", key)
+		case utf8.RuneCountInString(value) > 256:
+			return fmt.Errorf("upload.tags value for %q must be at most 256 characters", key)
+		case !objectTagPattern.MatchString(value):
+			return fmt.Errorf("upload.tags value for %q contains characters that S3 doesn't allow", key)
+		}
+	}
+	return nil
+}
+
+// checkPackagePaths ensures that every file in the deployment package has a
+// distinct path that stays within the package when extracted.
+func (u *UploadConfig) checkPackagePaths() error {
+	entrypoint := lo.CoalesceOrEmpty(u.Entrypoint, "bootstrap")
+	if !isPackagePath(entrypoint) {
+		return fmt.Errorf("upload.entrypoint %q must be a relative path within the package", entrypoint)
+	}
+
+	seen := map[string]bool{entrypoint: true}
+	for _, file := range u.ExtraFiles {
+		if file.Source == "" {
+			return fmt.Errorf("upload.extra_files entry for %q has no source", file.ArchivePath)
+		}
+		if !isPackagePath(file.ArchivePath) {
+			return fmt.Errorf("upload.extra_files archive_path %q must be a relative path within the package", file.ArchivePath)
+		}
+		if file.ArchivePath == entrypoint {
+			return fmt.Errorf("upload.extra_files archive_path %q would replace the entrypoint", file.ArchivePath)
+		}
+		if seen[file.ArchivePath] {
+			return fmt.Errorf("upload.extra_files archive_path %q appears more than once", file.ArchivePath)
+		}
+		seen[file.ArchivePath] = true
+	}
+	return nil
+}
+
+// isPackagePath returns true if p is a clean, slash-separated relative path
+// with no ".." elements, which can't escape the directory that a package is
+// extracted to.
+func isPackagePath(p string) bool {
+	return fs.ValidPath(p) && p != "." && !strings.Contains(p, `\`)
 }
 
 // TemplateConfig represents the configuration of the AWS CloudFormation
 // template associated with the deployment.
 type TemplateConfig struct {
-	Path         string   `toml:"path"`
-	Capabilities []string `toml:"capabilities"`
+	Path             string   `toml:"path"`
+	Capabilities     []string `toml:"capabilities"`
+	RoleARN          string   `toml:"role_arn"`
+	NotificationARNs []string `toml:"notification_arns"`
+
+	// UseS3 uploads the template to the upload bucket for every deployment.
+	// Templates larger than CloudFormation's limit for inline templates are
+	// always uploaded.
+	UseS3 bool `toml:"use_s3"`
+
+	// ValidateParameters checks the parameters for each deployment against the
+	// template's declared parameters before creating a change set: "warn" logs
+	// any problems, and "error" fails the deployment. Empty disables the check.
+	ValidateParameters string `toml:"validate_parameters"`
+
+	// Layers are the ARNs of Lambda layer versions for every stack, which
+	// deployments pass to the template in the LayerArns parameter.
+	Layers []string `toml:"layers"`
+
+	// StackPolicy is the CloudFormation stack policy for every stack, either as
+	// inline JSON or as the path to a JSON file.
+	StackPolicy string `toml:"stack_policy"`
+}
+
+func (t *TemplateConfig) check() error {
+	switch t.ValidateParameters {
+	case "", "warn", "error":
+	default:
+		return fmt.Errorf(`template.validate_parameters must be "warn" or "error", got %q`, t.ValidateParameters)
+	}
+	return checkLayerARNs("template.layers", t.Layers)
+}
+
+// checkLayerARNs returns an error if any of the layers is not the ARN of a
+// Lambda layer version.
+func checkLayerARNs(path string, layers []string) error {
+	for _, layer := range layers {
+		if !strings.HasPrefix(layer, "arn:") || !strings.Contains(layer, ":layer:") {
+			return fmt.Errorf("%s: %q is not a Lambda layer version ARN", path, layer)
+		}
+	}
+	return nil
+}
+
+// HooksConfig represents commands to run around hfc's build and deployment
+// steps. Each command is split into arguments with shell-style quoting, but is
+// not otherwise interpreted by a shell. Pre-deploy hooks run after the template
+// is read, so they can't generate it, but before it is uploaded. Post-build hooks also run
+// when a build reuses an unchanged binary.
+type HooksConfig struct {
+	PreBuild   []string `toml:"pre_build"`
+	PostBuild  []string `toml:"post_build"`
+	PreDeploy  []string `toml:"pre_deploy"`
+	PostDeploy []string `toml:"post_deploy"`
 }
 
 // StackConfig represents the configuration of an AWS CloudFormation stack, a
 // specific deployment of the CloudFormation template with a unique set of
 // parameters.
 type StackConfig struct {
-	Name       string            `toml:"name"`
-	Parameters map[string]string `toml:"parameters"`
+	Name       string     `toml:"name"`
+	Parameters Parameters `toml:"parameters"`
+	// Protected requires interactive confirmation before hfc modifies the stack.
+	Protected bool `toml:"protected"`
+	// RoleARN overrides the template's service role for this stack.
+	RoleARN string `toml:"role_arn"`
+	// PublishAlias optionally publishes a new Lambda version after each deploy.
+	PublishAlias PublishAliasConfig `toml:"publish_alias"`
+	// Layers are the ARNs of Lambda layer versions for this stack, in addition
+	// to the template's layers.
+	Layers []string `toml:"layers"`
+	// StackPolicy overrides the template's stack policy for this stack.
+	StackPolicy string `toml:"stack_policy"`
+}
+
+func (s *StackConfig) check() error {
+	if (s.PublishAlias.Alias == "") != (s.PublishAlias.FunctionOutput == "") {
+		return fmt.Errorf("stack %s: publish_alias requires both alias and function_output", s.Name)
+	}
+	return checkLayerARNs("stack "+s.Name+": layers", s.Layers)
+}
+
+// Parameters are the values of CloudFormation stack parameters.
+//
+// In TOML, a value may be a string, number, or boolean, or an array of these,
+// which is joined with commas as CloudFormation expects for List and
+// CommaDelimitedList parameters. A table, or an array containing a table, is
+// encoded as JSON for parameters that hold structured data.
+type Parameters map[string]string
+
+// UnmarshalTOML implements toml.Unmarshaler.
+func (p *Parameters) UnmarshalTOML(data any) error {
+	table, ok := data.(map[string]any)
+	if !ok {
+		return fmt.Errorf("parameters must be a table, got %T", data)
+	}
+	result := make(Parameters, len(table))
+	for key, value := range table {
+		formatted, err := formatParameterValue(value)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", key, err)
+		}
+		result[key] = formatted
+	}
+	*p = result
+	return nil
+}
+
+// formatParameterValue returns the CloudFormation form of a decoded TOML value.
+func formatParameterValue(value any) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case map[string]any, []map[string]any:
+		return formatParameterJSON(value)
+	case []any:
+		elems := make([]string, len(value))
+		for i, elem := range value {
+			switch elem.(type) {
+			case map[string]any, []any:
+				return formatParameterJSON(value)
+			}
+			formatted, err := formatParameterValue(elem)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(formatted, ",") {
+				return "", fmt.Errorf("list element %q contains a comma", formatted)
+			}
+			elems[i] = formatted
+		}
+		return strings.Join(elems, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", value)
+	}
+}
+
+func formatParameterJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+// PublishAliasConfig represents the configuration for publishing a new version
+// of a stack's Lambda function after each deployment, and pointing an alias at
+// the new version.
+type PublishAliasConfig struct {
+	// FunctionOutput is the key of the stack output whose value is the name or
+	// ARN of the Lambda function.
+	FunctionOutput string `toml:"function_output"`
+	// Alias is the name of the Lambda alias to point at the new version.
+	Alias string `toml:"alias"`
 }
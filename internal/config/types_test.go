@@ -0,0 +1,144 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUploadConfigPackagePaths(t *testing.T) {
+	testCases := []struct {
+		description string
+		upload      UploadConfig
+		wantErr     bool
+	}{{
+		description: "defaults",
+		upload:      UploadConfig{},
+	}, {
+		description: "extra files",
+		upload: UploadConfig{
+			Entrypoint: "bin/handler",
+			ExtraFiles: []PackageFileConfig{
+				{Source: "NOTICE", ArchivePath: "NOTICE"},
+				{Source: "config/prod.json", ArchivePath: "config/app.json"},
+			},
+		},
+	}, {
+		description: "parent directory",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{Source: "NOTICE", ArchivePath: "../NOTICE"}}},
+		wantErr:     true,
+	}, {
+		description: "nested parent directory",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{Source: "NOTICE", ArchivePath: "config/../../NOTICE"}}},
+		wantErr:     true,
+	}, {
+		description: "absolute",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{Source: "NOTICE", ArchivePath: "/etc/NOTICE"}}},
+		wantErr:     true,
+	}, {
+		description: "backslash",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{Source: "NOTICE", ArchivePath: `..\NOTICE`}}},
+		wantErr:     true,
+	}, {
+		description: "empty",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{Source: "NOTICE"}}},
+		wantErr:     true,
+	}, {
+		description: "no source",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{ArchivePath: "NOTICE"}}},
+		wantErr:     true,
+	}, {
+		description: "replaces bootstrap",
+		upload:      UploadConfig{ExtraFiles: []PackageFileConfig{{Source: "wrapper.sh", ArchivePath: "bootstrap"}}},
+		wantErr:     true,
+	}, {
+		description: "replaces custom entrypoint",
+		upload: UploadConfig{
+			Entrypoint: "handler",
+			ExtraFiles: []PackageFileConfig{{Source: "wrapper.sh", ArchivePath: "handler"}},
+		},
+		wantErr: true,
+	}, {
+		description: "duplicate",
+		upload: UploadConfig{ExtraFiles: []PackageFileConfig{
+			{Source: "NOTICE", ArchivePath: "NOTICE"},
+			{Source: "LICENSE", ArchivePath: "NOTICE"},
+		}},
+		wantErr: true,
+	}, {
+		description: "invalid entrypoint",
+		upload:      UploadConfig{Entrypoint: "../bootstrap"},
+		wantErr:     true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := tc.upload.check()
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("unexpected error result; got %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestParametersUnmarshal(t *testing.T) {
+	const input = `
+[parameters]
+Environment = "staging"
+Port = 8080
+Ratio = 0.5
+Debug = true
+Subnets = ["subnet-1", "subnet-2"]
+Ports = [80, 443]
+Settings = { retries = 3, mode = "fast" }
+Routes = [{ path = "/" }]
+`
+	var got struct{ Parameters Parameters }
+	if _, err := toml.Decode(input, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Parameters{
+		"Environment": "staging",
+		"Port":        "8080",
+		"Ratio":       "0.5",
+		"Debug":       "true",
+		"Subnets":     "subnet-1,subnet-2",
+		"Ports":       "80,443",
+		"Settings":    `{"mode":"fast","retries":3}`,
+		"Routes":      `[{"path":"/"}]`,
+	}
+	if diff := cmp.Diff(want, got.Parameters); diff != "" {
+		t.Errorf("unexpected parameters (-want +got):\n%s", diff)
+	}
+}
+
+func TestParametersUnmarshalInvalid(t *testing.T) {
+	testCases := []string{
+		`parameters = { Names = ["a,b", "c"] }`,
+		`parameters = { Created = 2025-01-01T00:00:00Z }`,
+	}
+	for _, input := range testCases {
+		var got struct{ Parameters Parameters }
+		if _, err := toml.Decode(input, &got); err == nil {
+			t.Errorf("Decode succeeded for %s", input)
+		}
+	}
+}
+
+func TestCheckRequired(t *testing.T) {
+	config := Config{
+		Project: ProjectConfig{Name: "hfc"},
+		Build:   BuildConfig{Path: "./cmd/hfc"},
+	}
+	if err := config.CheckRequired("project.name", "build.path"); err != nil {
+		t.Errorf("unexpected error for present settings: %v", err)
+	}
+
+	err := config.CheckRequired("project.name", "upload.bucket", "template.path")
+	const want = "missing required configuration: upload.bucket, template.path"
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error for missing settings; got %v, want %q", err, want)
+	}
+}
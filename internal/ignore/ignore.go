@@ -0,0 +1,190 @@
+// Package ignore matches paths against patterns in the syntax of .gitignore
+// files.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// Filename is the name of the file that lists patterns for paths that hfc
+// ignores, in the directory of the configuration file.
+const Filename = ".hfcignore"
+
+// Matcher matches slash-separated relative paths against a list of patterns.
+// The zero value matches nothing.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Load reads patterns from the file at path. A missing file has no patterns.
+func Load(path string) (Matcher, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Matcher{}, nil
+	}
+	if err != nil {
+		return Matcher{}, err
+	}
+	defer f.Close()
+
+	m, err := Parse(f)
+	if err != nil {
+		return Matcher{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse reads patterns from r, one per line, using the syntax of .gitignore:
+//
+//   - Blank lines and lines starting with "#" are ignored.
+//   - A leading "!" re-includes paths that an earlier pattern excluded, except
+//     for paths within an excluded directory.
+//   - A trailing "/" matches only directories.
+//   - A pattern with a "/" at the start or in the middle is relative to the
+//     directory of the file. Any other pattern matches a name at any depth.
+//   - "*", "?", and "[...]" match within a single path element, while "**"
+//     matches any number of elements.
+//   - A backslash escapes the character that follows it.
+func Parse(r io.Reader) (Matcher, error) {
+	var m Matcher
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		p, ok, err := parsePattern(scanner.Text())
+		if err != nil {
+			return Matcher{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		if ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m, scanner.Err()
+}
+
+func parsePattern(text string) (pattern, bool, error) {
+	text = trimTrailingSpaces(text)
+	if text == "" || strings.HasPrefix(text, "#") {
+		return pattern{}, false, nil
+	}
+
+	var p pattern
+	if rest, ok := strings.CutPrefix(text, "!"); ok {
+		p.negate = true
+		text = rest
+	}
+	if rest, ok := strings.CutSuffix(text, "/"); ok {
+		p.dirOnly = true
+		text = rest
+	}
+	anchored := strings.Contains(text, "/")
+	text = strings.TrimPrefix(text, "/")
+	if text == "" {
+		return pattern{}, false, nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !anchored {
+		expr.WriteString("(?:.*/)?")
+	}
+	elements := strings.Split(text, "/")
+	for i, element := range elements {
+		last := i == len(elements)-1
+		if element == "**" {
+			expr.WriteString(lo.Ternary(last, ".*", "(?:.*/)?"))
+			continue
+		}
+		if err := writeElementExpr(&expr, element); err != nil {
+			return pattern{}, false, err
+		}
+		if !last {
+			expr.WriteString("/")
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return pattern{}, false, fmt.Errorf("invalid pattern %q", text)
+	}
+	p.re = re
+	return p, true, nil
+}
+
+// writeElementExpr writes a regular expression for one element of a pattern,
+// which matches within a single element of a path.
+func writeElementExpr(expr *strings.Builder, element string) error {
+	for i := 0; i < len(element); i++ {
+		switch c := element[i]; c {
+		case '\\':
+			if i+1 < len(element) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(element[i : i+1]))
+			}
+		case '*':
+			expr.WriteString("[^/]*")
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(element[i+1:], ']')
+			if end < 0 {
+				return fmt.Errorf("unterminated character class in %q", element)
+			}
+			class := element[i+1 : i+1+end]
+			if rest, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + rest
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return nil
+}
+
+// trimTrailingSpaces removes trailing spaces from a line, except for a space
+// escaped with a backslash.
+func trimTrailingSpaces(text string) string {
+	for strings.HasSuffix(text, " ") && !strings.HasSuffix(text, `\ `) {
+		text = text[:len(text)-1]
+	}
+	return text
+}
+
+// Match returns true if the slash-separated path, relative to the directory of
+// the patterns, is ignored. A path within an ignored directory is always
+// ignored, as with .gitignore.
+func (m Matcher) Match(path string, isDir bool) bool {
+	for i := range len(path) {
+		if path[i] == '/' && m.matchOne(path[:i], true) {
+			return true
+		}
+	}
+	return m.matchOne(path, isDir)
+}
+
+// matchOne returns true if the last pattern that matches the path excludes it.
+func (m Matcher) matchOne(path string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if (!p.dirOnly || isDir) && p.re.MatchString(path) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
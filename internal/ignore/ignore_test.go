@@ -0,0 +1,74 @@
+package ignore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	const patterns = `
+# Comments and blank lines are skipped.
+
+*.md
+!README.md
+/testdata/
+docs/**/*.png
+build/
+**/fixtures/*.json
+\#notes
+cache-[0-9]
+`
+	m, err := Parse(strings.NewReader(patterns))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"CHANGELOG.md", false, true},
+		{"pkg/api/NOTES.md", false, true},
+		{"README.md", false, false},
+		{"pkg/README.md", false, false},
+		{"testdata", true, true},
+		{"testdata/input.txt", false, true},
+		{"pkg/testdata/input.txt", false, false},
+		{"docs/diagram.png", false, true},
+		{"docs/a/b/diagram.png", false, true},
+		{"docs/diagram.svg", false, false},
+		{"build", false, false},
+		{"build", true, true},
+		{"cmd/build/main.go", false, true},
+		{"fixtures/user.json", false, true},
+		{"pkg/fixtures/user.json", false, true},
+		{"pkg/fixtures/nested/user.json", false, false},
+		{"#notes", false, true},
+		{"cache-1", false, true},
+		{"cache-x", false, false},
+		{"main.go", false, false},
+	}
+	for _, tc := range testCases {
+		if got := m.Match(tc.path, tc.isDir); got != tc.want {
+			t.Errorf("Match(%q, %v) = %v, want %v", tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse(strings.NewReader("ok\nbad[\n")); err == nil {
+		t.Error("Parse succeeded with unterminated character class")
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	m, err := Load(filepath.Join(t.TempDir(), Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Match("main.go", false) {
+		t.Error("empty matcher matched a path")
+	}
+}
@@ -2,11 +2,14 @@
 package shelley
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/kballard/go-shellquote"
@@ -20,7 +23,8 @@ type ExitError = *exec.ExitError
 //
 // If err is an ExitError, the process will exit silently with the same code as
 // the command that generated the error. Otherwise, the error will be logged
-// with the log package and the process will exit with code 1.
+// at the error level with the log/slog package and the process will exit with
+// code 1.
 //
 // This enables an extremely limited but easy to use form of error handling,
 // roughly analogous to "set -e" in a shell script, but without the complex
@@ -36,7 +40,8 @@ func ExitIfError(err error) {
 		os.Exit(exitErr.ExitCode())
 	}
 
-	log.Fatal(err)
+	slog.Error(err.Error())
+	os.Exit(1)
 }
 
 // DefaultContext is the Context for commands created by the top level Command
@@ -77,6 +82,10 @@ type Cmd struct {
 	cmd     *exec.Cmd
 	args    []string
 	envs    []string
+	dir     string
+
+	cleanEnv bool
+	keepEnv  []string
 }
 
 // Command initializes a new command using DefaultContext.
@@ -93,10 +102,116 @@ func (c *Cmd) Env(name, value string) *Cmd {
 	return c
 }
 
+// CleanEnv makes the command start from an empty environment instead of
+// inheriting the environment of the current process. Only the named variables
+// are carried forward from the current process, along with any values set by
+// Env.
+func (c *Cmd) CleanEnv(keep ...string) *Cmd {
+	c.cleanEnv = true
+	c.keepEnv = append(c.keepEnv, keep...)
+	return c
+}
+
+// Dir sets the working directory of the command. By default, commands run in
+// the current directory of the calling process.
+func (c *Cmd) Dir(path string) *Cmd {
+	c.dir = path
+	return c
+}
+
 // Run runs the command and waits for it to complete.
 func (c *Cmd) Run() error {
+	c.prepare()
+	return c.cmd.Run()
+}
+
+// CombinedOutput runs the command and returns its combined stdout and stderr,
+// instead of writing them to the Context's Stdout and Stderr.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	c.prepare()
+	var output bytes.Buffer
+	c.cmd.Stdout = &output
+	c.cmd.Stderr = &output
+	err := c.cmd.Run()
+	return output.Bytes(), err
+}
+
+// Pipeline runs the provided commands concurrently, connecting the stdout of
+// each command to the stdin of the next, and waits for all of them to complete.
+// The stdin of the first command and the stdout of the last command are taken
+// from their respective contexts.
+//
+// If any command fails, Pipeline returns the error from the earliest failing
+// command in the pipeline.
+func Pipeline(cmds ...*Cmd) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+
+	for _, c := range cmds {
+		c.prepare()
+	}
+
+	var pipeFiles []*os.File
+	defer func() {
+		for _, f := range pipeFiles {
+			f.Close()
+		}
+	}()
+	for i := range len(cmds) - 1 {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		pipeFiles = append(pipeFiles, r, w)
+		cmds[i].cmd.Stdout = w
+		cmds[i+1].cmd.Stdin = r
+	}
+
+	var (
+		started  []*Cmd
+		startErr error
+	)
+	for _, c := range cmds {
+		if startErr = c.cmd.Start(); startErr != nil {
+			break
+		}
+		started = append(started, c)
+	}
+
+	// The children hold their own copies of the pipe file descriptors. Ours must
+	// be closed so that each stage sees EOF when the previous stage exits.
+	for _, f := range pipeFiles {
+		f.Close()
+	}
+	pipeFiles = nil
+
+	var waitErr error
+	for _, c := range started {
+		if err := c.cmd.Wait(); err != nil && waitErr == nil {
+			waitErr = err
+		}
+	}
+	if startErr != nil {
+		return startErr
+	}
+	return waitErr
+}
+
+func (c *Cmd) prepare() {
 	if c.context.DebugLogger != nil {
 		var envString strings.Builder
+		if c.dir != "" && !isWorkingDir(c.dir) {
+			envString.WriteString("cd ")
+			envString.WriteString(shellquote.Join(c.dir))
+			envString.WriteString(" && ")
+		}
+		if c.cleanEnv {
+			envString.WriteString("env -i ")
+			for _, name := range c.keepEnv {
+				envString.WriteString(name + `="$` + name + `" `)
+			}
+		}
 		for _, env := range c.envs {
 			split := strings.SplitN(env, "=", 2)
 			envString.WriteString(split[0])
@@ -108,10 +223,34 @@ func (c *Cmd) Run() error {
 	}
 
 	c.cmd = exec.Command(c.args[0], c.args[1:]...)
-	c.cmd.Env = append(os.Environ(), c.envs...)
+	c.cmd.Env = append(c.baseEnv(), c.envs...)
+	c.cmd.Dir = c.dir
 	c.cmd.Stdin = c.context.Stdin
 	c.cmd.Stdout = c.context.Stdout
 	c.cmd.Stderr = c.context.Stderr
+}
 
-	return c.cmd.Run()
+// baseEnv returns the environment that the command starts from, before adding
+// values set by Env.
+func (c *Cmd) baseEnv() []string {
+	if !c.cleanEnv {
+		return os.Environ()
+	}
+	// A nil environment would make exec inherit the current process's.
+	env := []string{}
+	for _, name := range c.keepEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+func isWorkingDir(path string) bool {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	return err == nil && abs == cwd
 }
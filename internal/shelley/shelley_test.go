@@ -15,7 +15,7 @@ func TestMain(m *testing.M) {
 	// Or, maybe it's not, and I don't. This is sort of a hack to globally skip
 	// these tests if we can't assume that a reasonable baseline set of commands
 	// is available.
-	requiredCommands := []string{"sh", "cat", "false", "sort"}
+	requiredCommands := []string{"sh", "cat", "false", "sort", "pwd"}
 	for _, cmd := range requiredCommands {
 		if _, err := exec.LookPath(cmd); err != nil {
 			return
@@ -49,6 +49,25 @@ func TestRunWithoutOptions(t *testing.T) {
 	}
 }
 
+func TestCombinedOutput(t *testing.T) {
+	var stdout, stderr strings.Builder
+	context := &Context{Stdout: &stdout, Stderr: &stderr}
+
+	output, err := context.Command("sh", "-c", "echo stdout; echo stderr 1>&2; exit 2").CombinedOutput()
+	var exitErr ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 2 {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	const wantOutput = "stdout\nstderr\n"
+	if string(output) != wantOutput {
+		t.Errorf("unexpected output; got %q, want %q", output, wantOutput)
+	}
+	if stdout.Len() > 0 || stderr.Len() > 0 {
+		t.Errorf("output leaked to context; got stdout %q, stderr %q", stdout.String(), stderr.String())
+	}
+}
+
 func TestExitError(t *testing.T) {
 	err := Command("false").Run()
 	var exitErr ExitError
@@ -95,3 +114,119 @@ func TestEnv(t *testing.T) {
 		t.Errorf("unexpected output; got %q, want %q", stdout.String(), wantStdout)
 	}
 }
+
+func TestCleanEnv(t *testing.T) {
+	t.Setenv("SHELLEY_LEAK", "leak")
+	t.Setenv("SHELLEY_KEEP", "keep")
+
+	var stdout strings.Builder
+	context := &Context{Stdout: &stdout}
+
+	err := context.
+		Command("sh", "-c", `echo "$SHELLEY_LEAK|$SHELLEY_KEEP|$SHELLEY"`).
+		CleanEnv("PATH", "SHELLEY_KEEP", "SHELLEY_UNSET").
+		Env("SHELLEY", "shelley").
+		Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wantStdout = "|keep|shelley\n"
+	if stdout.String() != wantStdout {
+		t.Errorf("unexpected output; got %q, want %q", stdout.String(), wantStdout)
+	}
+}
+
+func TestCleanEnvEmpty(t *testing.T) {
+	t.Setenv("SHELLEY_LEAK", "leak")
+
+	var stdout strings.Builder
+	context := &Context{Stdout: &stdout}
+
+	// Use an absolute path, since PATH isn't kept.
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := context.Command(sh, "-c", `echo "$SHELLEY_LEAK"`).CleanEnv().Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	const wantStdout = "\n"
+	if stdout.String() != wantStdout {
+		t.Errorf("unexpected output; got %q, want %q", stdout.String(), wantStdout)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	var stdout, debug strings.Builder
+	context := &Context{
+		Stdout:      &stdout,
+		DebugLogger: log.New(&debug, "", 0),
+	}
+
+	err := Pipeline(
+		context.Command("sh", "-c", "echo two; echo three; echo one"),
+		context.Command("sort").Env("LC_ALL", "C"),
+		context.Command("cat"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const wantStdout = "one\nthree\ntwo\n"
+	if stdout.String() != wantStdout {
+		t.Errorf("unexpected output; got %q, want %q", stdout.String(), wantStdout)
+	}
+
+	const wantDebug = "sh -c 'echo two; echo three; echo one'\nLC_ALL=C sort\ncat\n"
+	if debug.String() != wantDebug {
+		t.Errorf("unexpected debug; got %q, want %q", debug.String(), wantDebug)
+	}
+}
+
+func TestPipelineExitError(t *testing.T) {
+	var stdout strings.Builder
+	context := &Context{Stdout: &stdout}
+
+	err := Pipeline(
+		context.Command("sh", "-c", "echo shelley; exit 3"),
+		context.Command("cat"),
+	)
+	var exitErr ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("error was not an ExitError: %v", err)
+	}
+	if code := exitErr.ExitCode(); code != 3 {
+		t.Errorf("unexpected exit code; got %d, want 3", code)
+	}
+
+	const wantStdout = "shelley\n"
+	if stdout.String() != wantStdout {
+		t.Errorf("unexpected output; got %q, want %q", stdout.String(), wantStdout)
+	}
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	var stdout, debug strings.Builder
+	context := &Context{
+		Stdout:      &stdout,
+		DebugLogger: log.New(&debug, "", 0),
+	}
+
+	err := context.Command("pwd").Dir(dir).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStdout := dir + "\n"
+	if stdout.String() != wantStdout {
+		t.Errorf("unexpected output; got %q, want %q", stdout.String(), wantStdout)
+	}
+
+	wantDebug := "cd " + dir + " && pwd\n"
+	if debug.String() != wantDebug {
+		t.Errorf("unexpected debug; got %q, want %q", debug.String(), wantDebug)
+	}
+}
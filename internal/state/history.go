@@ -0,0 +1,74 @@
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// UploadRecord describes a deployment package that was uploaded for the
+// project.
+type UploadRecord struct {
+	Time time.Time `json:"time"`
+	Key  string    `json:"key"`
+	Size int64     `json:"size"`
+	// Revision is the version control revision that the binary was built from,
+	// if known.
+	Revision string `json:"revision,omitempty"`
+	// SHA256 is the base64-encoded SHA-256 checksum of the package, in the form
+	// of S3's ChecksumSHA256.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// UploadHistoryPath returns the absolute path to the file containing the upload
+// history, with one JSON record per line.
+func (s State) UploadHistoryPath() string {
+	return s.Path("upload-history.jsonl")
+}
+
+// AppendUploadHistory adds the record to the end of the upload history.
+func (s State) AppendUploadHistory(record UploadRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.UploadHistoryPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	// Appending the whole line in one write keeps concurrent uploads from
+	// interleaving their records.
+	_, err = file.Write(append(line, '\n'))
+	return errors.Join(err, file.Close())
+}
+
+// ReadUploadHistory returns the records in the upload history, oldest first.
+// The history is empty if nothing has been uploaded.
+func (s State) ReadUploadHistory() ([]UploadRecord, error) {
+	file, err := os.Open(s.UploadHistoryPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []UploadRecord
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record UploadRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", s.UploadHistoryPath(), line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
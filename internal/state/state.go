@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // Dirname is the name of the state directory next to the configuration file.
@@ -36,14 +37,33 @@ func Get(configPath string) (State, error) {
 	return State{path: statePath}, nil
 }
 
+// Open returns the state associated with the configuration at the provided
+// path, without creating the state directory. Reads from a missing state
+// directory find nothing, as if it were empty, and writes fail.
+func Open(configPath string) State {
+	return State{path: filepath.Join(filepath.Dir(configPath), Dirname)}
+}
+
 // BinaryPath returns the relative file path to the named Go binary in the
 // state directory.
 func (s State) BinaryPath(name string) (string, error) {
+	return s.BinaryPathIn("", name)
+}
+
+// BinaryPathIn returns the relative file path to the named Go binary in the
+// provided output directory, or in the state directory if outputDir is empty.
+func (s State) BinaryPathIn(outputDir, name string) (string, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return "", err
 	}
 	fullPath := s.Path("output", name)
+	if outputDir != "" {
+		fullPath, err = filepath.Abs(filepath.Join(outputDir, name))
+		if err != nil {
+			return "", err
+		}
+	}
 	return filepath.Rel(cwd, fullPath)
 }
 
@@ -53,6 +73,52 @@ func (s State) LatestLambdaPackagePath() string {
 	return s.Path("latest-lambda-package")
 }
 
+// ErrNoLatestPackage is returned by ReadLatestPackage when no Lambda deployment
+// package has been uploaded.
+var ErrNoLatestPackage = errors.New("no deployment package has been uploaded")
+
+// ReadLatestPackage returns the S3 key of the latest Lambda deployment package.
+// If there is none, ReadLatestPackage returns ErrNoLatestPackage.
+func (s State) ReadLatestPackage() (string, error) {
+	data, err := os.ReadFile(s.LatestLambdaPackagePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ErrNoLatestPackage
+	}
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", ErrNoLatestPackage
+	}
+	return key, nil
+}
+
+// WriteLatestPackage records key as the S3 key of the latest Lambda deployment
+// package. The file is replaced atomically, so that an interrupted write or a
+// concurrent upload can't leave a partial key behind.
+func (s State) WriteLatestPackage(key string) error {
+	return writeFileAtomic(s.LatestLambdaPackagePath(), []byte(key+"\n"))
+}
+
+// writeFileAtomic replaces the file at path with data, by writing a temporary
+// file in the same directory and renaming it into place.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	err = errors.Join(err, file.Chmod(0644), file.Close())
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
 // Path returns the absolute file path formed by joining the provided path
 // elements to the state directory path.
 func (s State) Path(parts ...string) string {
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLatestPackage(t *testing.T) {
+	s, err := Get(filepath.Join(t.TempDir(), "hfc.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ReadLatestPackage(); !errors.Is(err, ErrNoLatestPackage) {
+		t.Fatalf("unexpected error before upload; got %v, want %v", err, ErrNoLatestPackage)
+	}
+
+	for _, key := range []string{"hfc/1.zip", "hfc/2.zip"} {
+		if err := s.WriteLatestPackage(key); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.ReadLatestPackage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != key {
+			t.Errorf("unexpected latest package; got %q, want %q", got, key)
+		}
+	}
+
+	entries, err := os.ReadDir(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("unexpected files in state directory after writes; got %d, want 1", len(entries))
+	}
+
+	if err := os.WriteFile(s.LatestLambdaPackagePath(), []byte("\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReadLatestPackage(); !errors.Is(err, ErrNoLatestPackage) {
+		t.Errorf("unexpected error for empty file; got %v, want %v", err, ErrNoLatestPackage)
+	}
+}